@@ -0,0 +1,25 @@
+// Command ilogring prints the records of an ilogger ring file, oldest first
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/jbsturgeon/ilogger"
+)
+
+func main() {
+	if len(os.Args) != 2 {
+		fmt.Fprintln(os.Stderr, "usage: ilogring <ring file>")
+		os.Exit(2)
+	}
+
+	records, err := ilogger.ReadRing(os.Args[1])
+	for _, rec := range records {
+		fmt.Printf("%s\n", rec)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ilogring: %v\n", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,92 @@
+package ilogger
+
+import "time"
+
+// Config describes a logger's current settings, for dumping while debugging the
+// logger itself
+type Config struct {
+	Level string `json:"level"`
+	// EnabledLevels lists the levels selected with EnableLevels, empty for threshold filtering
+	EnabledLevels []string `json:"enabled_levels,omitempty"`
+	// Disabled is set when LOG_DISABLE turns all logging off
+	Disabled bool   `json:"disabled"`
+	Format   string `json:"format"`
+	Flags    int    `json:"flags"`
+
+	ColorMode    string `json:"color_mode"`
+	Color        string `json:"color"`
+	ColorEnabled bool   `json:"color_enabled"`
+
+	Path string `json:"path"`
+	// File is the open log file, or "" before the first one is opened
+	File string `json:"file"`
+
+	Rotation         string        `json:"rotation"`
+	MaxBytes         int64         `json:"max_bytes"`
+	MaxFiles         int           `json:"max_files"`
+	WriteBufferBytes int           `json:"write_buffer_bytes"`
+	OnWriteError     string        `json:"on_write_error"`
+	DedupWindow      time.Duration `json:"dedup_window"`
+
+	FatalCode int `json:"fatal_code"`
+
+	Version   string   `json:"version,omitempty"`
+	Component string   `json:"component,omitempty"`
+	Tags      []string `json:"tags,omitempty"`
+
+	Header       bool `json:"header"`
+	Sequence     bool `json:"sequence"`
+	LazyOpen     bool `json:"lazy_open"`
+	SkipEmpty    bool `json:"skip_empty"`
+	NoCaller     bool `json:"no_caller"`
+	MirrorStdlib bool `json:"mirror_stdlib"`
+}
+
+// Config returns a snapshot of the logger's settings
+func (i *ILog) Config() Config {
+	Init()
+
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	level, mask := i.level()
+	c := Config{
+		Level:            level.String(),
+		Disabled:         logDisabled,
+		Format:           formatName(i.encoder()),
+		Flags:            i.Flags,
+		ColorMode:        i.ColorMode.String(),
+		Color:            i.Color.String(),
+		ColorEnabled:     i.colorEnabled(),
+		Path:             i.Path,
+		Rotation:         i.Rotation.String(),
+		MaxBytes:         i.MaxBytes,
+		MaxFiles:         i.MaxFiles,
+		WriteBufferBytes: i.WriteBufferBytes,
+		OnWriteError:     i.OnWriteError.String(),
+		DedupWindow:      i.DedupWindow,
+		FatalCode:        i.fatalCode(),
+		Version:          i.Version,
+		Component:        i.Component,
+		Tags:             i.Tags,
+		Header:           i.Header,
+		Sequence:         i.Sequence,
+		LazyOpen:         i.LazyOpen,
+		SkipEmpty:        i.SkipEmpty,
+		NoCaller:         i.NoCaller,
+		MirrorStdlib:     i.MirrorStdlib,
+	}
+	if c.Flags == 0 {
+		c.Flags = DefaultFlags
+	}
+	if i.logOpen {
+		c.File = i.logFile.Name()
+	}
+	for l := LMandatory; l != 0; l <<= 1 {
+		if mask&l != 0 {
+			c.EnabledLevels = append(c.EnabledLevels, l.String())
+		}
+	}
+
+	return c
+}
@@ -0,0 +1,69 @@
+package ilogger
+
+import (
+	"log"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestConfig(t *testing.T) {
+	dir := t.TempDir()
+	l := newTestLogger(t, &ILog{
+		Path:             dir,
+		Level:            LWarn,
+		Flags:            log.Lmsgprefix,
+		Encoder:          JSONEncoder{},
+		Color:            ColorNever,
+		ColorMode:        ColorLevel,
+		Rotation:         RotateWeekly,
+		MaxBytes:         1 << 20,
+		MaxFiles:         4,
+		WriteBufferBytes: 4096,
+		OnWriteError:     WriteErrorRetry,
+		DedupWindow:      time.Minute,
+		FatalCode:        7,
+		Version:          "1.2.3",
+		Component:        "billing",
+		Tags:             []string{"eu"},
+		Sequence:         true,
+		SkipEmpty:        true,
+	})
+	l.EnableLevels(LError | LDebug)
+
+	want := Config{
+		Level:            "WARN",
+		EnabledLevels:    []string{"ERROR", "DEBUG"},
+		Format:           "json",
+		Flags:            log.Lmsgprefix,
+		ColorMode:        "level",
+		Color:            "never",
+		Path:             dir,
+		File:             l.fileName(time.Now(), 0),
+		Rotation:         "weekly",
+		MaxBytes:         1 << 20,
+		MaxFiles:         4,
+		WriteBufferBytes: 4096,
+		OnWriteError:     "retry",
+		DedupWindow:      time.Minute,
+		FatalCode:        7,
+		Version:          "1.2.3",
+		Component:        "billing",
+		Tags:             []string{"eu"},
+		Sequence:         true,
+		SkipEmpty:        true,
+	}
+	if got := l.Config(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got  %+v\nwant %+v", got, want)
+	}
+}
+
+func TestConfigDefaults(t *testing.T) {
+	l := &ILog{Path: t.TempDir(), LazyOpen: true}
+	got := l.Config()
+
+	if got.Format != "text" || got.Flags != DefaultFlags || got.Color != "auto" || got.ColorMode != "line" ||
+		got.Rotation != "daily" || got.OnWriteError != "drop" || got.FatalCode != 1 || got.File != "" {
+		t.Errorf("unexpected defaults %+v", got)
+	}
+}
@@ -0,0 +1,115 @@
+package ilogger
+
+import (
+	"hash/fnv"
+	"sync"
+	"time"
+)
+
+// dedupSweepSize is the number of tracked messages above which expired ones are dropped
+const dedupSweepSize = 1024
+
+// dedupWindow tracks one distinct message within DedupWindow
+type dedupWindow struct {
+	start      time.Time
+	suppressed uint64
+}
+
+// dedup holds the state behind ILog.DedupWindow
+type dedup struct {
+	mu    sync.Mutex
+	seen  map[uint64]*dedupWindow
+	total uint64
+}
+
+// dedupKey hashes a message together with its level, so each level is sampled separately
+func dedupKey(level LogLevel, msg string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte{byte(level)})
+	h.Write([]byte(msg))
+	return h.Sum64()
+}
+
+// dedupe drops entries whose level and message were already written within DedupWindow.
+// The first entry of a new window carries a "suppressed" field counting the repeats
+// dropped in the previous one.
+func (i *ILog) dedupe(entries []Entry) []Entry {
+	now := time.Now()
+
+	kept := entries[:0]
+	for _, e := range entries {
+		suppressed, ok := i.dedup.allow(now, i.DedupWindow, e.Level, e.Message)
+		if !ok {
+			continue
+		}
+
+		if suppressed > 0 {
+			fields := make(map[string]interface{}, len(e.Fields)+1)
+			for k, v := range e.Fields {
+				fields[k] = v
+			}
+			fields["suppressed"] = suppressed
+			e.Fields = fields
+		}
+		kept = append(kept, e)
+	}
+
+	return kept
+}
+
+// allow reports whether msg at level starts a new window, returning the number of
+// repeats suppressed in the window it replaces
+func (d *dedup) allow(now time.Time, window time.Duration, level LogLevel, msg string) (uint64, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.seen == nil {
+		d.seen = make(map[uint64]*dedupWindow)
+	}
+
+	key := dedupKey(level, msg)
+	w, ok := d.seen[key]
+	if ok && now.Sub(w.start) < window {
+		w.suppressed++
+		d.total++
+		return 0, false
+	}
+
+	var suppressed uint64
+	if ok {
+		suppressed = w.suppressed
+	}
+	d.seen[key] = &dedupWindow{start: now}
+
+	if len(d.seen) > dedupSweepSize {
+		for k, w := range d.seen {
+			if now.Sub(w.start) >= window {
+				delete(d.seen, k)
+			}
+		}
+	}
+
+	return suppressed, true
+}
+
+// Suppressed returns how many entries DedupWindow has dropped as repeats
+func (i *ILog) Suppressed() uint64 {
+	i.dedup.mu.Lock()
+	defer i.dedup.mu.Unlock()
+
+	return i.dedup.total
+}
+
+// DedupSampler is the Sampler form of DedupWindow: it passes only the first of identical
+// messages at the same level within Window
+type DedupSampler struct {
+	Window time.Duration
+
+	dedup dedup
+}
+
+// ShouldLog reports whether msg has not been seen at level within Window
+func (s *DedupSampler) ShouldLog(level LogLevel, msg string) bool {
+	_, ok := s.dedup.allow(time.Now(), s.Window, level, msg)
+	return ok
+}
@@ -0,0 +1,55 @@
+package ilogger
+
+import (
+	"log"
+	"testing"
+	"time"
+)
+
+func TestDedupWindow(t *testing.T) {
+	l := newTestLogger(t, &ILog{Flags: log.Lmsgprefix, DedupWindow: time.Hour})
+
+	for n := 0; n < 3; n++ {
+		l.Errorf("disk full")
+		l.Errorf("timeout on %s", "db")
+		l.Warn("disk full")
+	}
+	l.Errorf("timeout on %s", "cache")
+
+	want := "ERROR - disk full\nERROR - timeout on db\nWARN - disk full\nERROR - timeout on cache\n"
+	if got := readLog(t, l); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if got := l.Suppressed(); got != 6 {
+		t.Errorf("Suppressed() = %d, want 6", got)
+	}
+}
+
+func TestDedupNewWindowCarriesCount(t *testing.T) {
+	var d dedup
+	start := time.Date(2023, 5, 10, 12, 0, 0, 0, time.UTC)
+
+	steps := []struct {
+		after      time.Duration
+		msg        string
+		ok         bool
+		suppressed uint64
+	}{
+		{0, "a", true, 0},
+		{time.Second, "a", false, 0},
+		{2 * time.Second, "b", true, 0},
+		{3 * time.Second, "a", false, 0},
+		{10 * time.Second, "a", true, 2},
+		{11 * time.Second, "a", false, 0},
+		{12 * time.Second, "b", true, 0},
+	}
+	for _, s := range steps {
+		suppressed, ok := d.allow(start.Add(s.after), 10*time.Second, LError, s.msg)
+		if ok != s.ok || suppressed != s.suppressed {
+			t.Errorf("%q at %v: got (%d, %v), want (%d, %v)", s.msg, s.after, suppressed, ok, s.suppressed, s.ok)
+		}
+	}
+	if d.total != 3 {
+		t.Errorf("total = %d, want 3", d.total)
+	}
+}
@@ -0,0 +1,84 @@
+package ilogger
+
+import (
+	"os"
+	"sync"
+)
+
+var (
+	defaultMu     sync.RWMutex
+	defaultLogger *ILog
+)
+
+// Default returns the logger behind the package-level functions. Until SetDefault is
+// called it writes to stderr at the LOG_LEVEL level, like the standard log package.
+func Default() *ILog {
+	defaultMu.RLock()
+	l := defaultLogger
+	defaultMu.RUnlock()
+	if l != nil {
+		return l
+	}
+
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+
+	if defaultLogger == nil {
+		Init()
+		defaultLogger = &ILog{Flags: DefaultFlags, Version: logVersion, fallback: os.Stderr}
+		defaultLogger.SetLogLevel(logLevelConfig)
+	}
+	return defaultLogger
+}
+
+// SetDefault makes l the logger behind the package-level functions; nil restores the
+// stderr logger
+func SetDefault(l *ILog) {
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+
+	defaultLogger = l
+}
+
+// The package-level functions call log directly so the caller's frame is where ILog's
+// own methods expect it.
+
+// Error logs to the default logger like ILog.Error
+func Error(err error) {
+	l := Default()
+	if l.enabled(LError) {
+		// skip entry, logError and Error to reach the caller
+		l.logError(3, err)
+	}
+}
+
+// Errorf logs to the default logger like ILog.Errorf
+func Errorf(formattedString string, params ...interface{}) {
+	Default().log(LError, true, formattedString, params...)
+}
+
+// Warn logs to the default logger like ILog.Warn
+func Warn(formattedString string, params ...interface{}) {
+	Default().log(LWarn, true, formattedString, params...)
+}
+
+// Info logs to the default logger like ILog.Info
+func Info(formattedString string, params ...interface{}) {
+	Default().log(LInfo, true, formattedString, params...)
+}
+
+// Debug logs to the default logger like ILog.Debug
+func Debug(formattedString string, params ...interface{}) {
+	Default().log(LDebug, true, formattedString, params...)
+}
+
+// Fatalf logs to the default logger like ILog.Fatalf, then exits with its FatalCode
+func Fatalf(formattedString string, params ...interface{}) {
+	l := Default()
+	l.fatal(l.fatalCode(), formattedString, params)
+}
+
+// Fatalfc logs to the default logger like ILog.Fatalfc, then exits with code
+func Fatalfc(code int, formattedString string, params ...interface{}) {
+	Default().fatal(code, formattedString, params)
+}
@@ -0,0 +1,47 @@
+package ilogger
+
+import (
+	"log"
+	"reflect"
+	"regexp"
+	"testing"
+)
+
+func TestSetDefault(t *testing.T) {
+	var codes []int
+	l := newTestLogger(t, &ILog{Flags: log.Lmsgprefix | log.Lshortfile, ExitFunc: func(c int) { codes = append(codes, c) }, FatalCode: 3})
+	SetDefault(l)
+	defer SetDefault(nil)
+
+	if Default() != l {
+		t.Fatal("Default() is not the logger passed to SetDefault")
+	}
+
+	Error(codedError{code: "E1"})
+	Errorf("e%d", 1)
+	Warn("w")
+	Info("i")
+	Debug("d")
+	Fatalf("f")
+	Fatalfc(4, "fc")
+
+	want := regexp.MustCompile(`^default_test\.go:\d+: failed with E1 error_code=E1
+default_test\.go:\d+: ERROR - e1
+default_test\.go:\d+: WARN - w
+default_test\.go:\d+: INFO - i
+default_test\.go:\d+: DEBUG - d
+default_test\.go:\d+: ERROR - f
+default_test\.go:\d+: ERROR - fc
+$`)
+	if got := readLog(t, l); !want.MatchString(got) {
+		t.Errorf("unexpected output %q", got)
+	}
+	if !reflect.DeepEqual(codes, []int{3, 4}) {
+		t.Errorf("exit codes = %v, want [3 4]", codes)
+	}
+
+	SetDefault(nil)
+	if d := Default(); d == l || d.fallback == nil {
+		t.Error("SetDefault(nil) did not restore the stderr logger")
+	}
+}
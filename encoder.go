@@ -0,0 +1,576 @@
+package ilogger
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"log"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Entry is a single log message as handed to an Encoder
+type Entry struct {
+	Time    time.Time
+	Level   LogLevel
+	Message string
+	// Caller is "file:line" of the logging call; text output only resolves it for
+	// log.Lshortfile or log.Llongfile, and ILog.NoCaller turns it off entirely
+	Caller    string
+	Component string
+	// Seq is the logger's sequence number, or 0 when Sequence is off
+	Seq    uint64
+	Fields map[string]interface{}
+	// Tags label the entry for downstream filtering, apart from its fields
+	Tags []string
+	// Labels are constant key/values, such as the pod and namespace, written as a
+	// "labels" object by the JSON encoders; text output leaves them out
+	Labels map[string]string
+
+	// noPrefix keeps the level prefix off the text line for Log, Error and Mandatory
+	noPrefix bool
+	// callerSet marks a Caller supplied by LogWithCaller, which text output shows even
+	// without the file flags
+	callerSet bool
+}
+
+// Encoder renders an Entry as one complete line, including the trailing newline
+type Encoder interface {
+	Encode(e Entry) []byte
+}
+
+// HeaderEncoder is an Encoder whose files start with a header line, such as CSV column names;
+// it is written once to each new file, after the Header metadata line if that is on
+type HeaderEncoder interface {
+	Encoder
+	FileHeader() []byte
+}
+
+// Color modes select which part of a text line gets the level's configured color
+const (
+	// ColorLine colors the entire line: prefix, timestamp, caller, message and fields
+	ColorLine = ColorMode(iota)
+	// ColorLevel colors only the level prefix (e.g. "ERROR - "), leaving the rest plain
+	ColorLevel
+)
+
+// ColorMode selects the colored region of a text line
+type ColorMode uint8
+
+// String returns "line" or "level"
+func (m ColorMode) String() string {
+	switch m {
+	case ColorLine:
+		return "line"
+	case ColorLevel:
+		return "level"
+	default:
+		return fmt.Sprintf("ColorMode(%d)", uint8(m))
+	}
+}
+
+// TextEncoder renders entries the way log.Logger does, followed by any fields as key=value pairs
+type TextEncoder struct {
+	// Flags are log.Logger flags; 0 uses DefaultFlags
+	Flags int
+	// Prefix starts each line, or goes just before the message with log.Lmsgprefix
+	Prefix string
+	// AllowControls writes newlines and other control characters in messages as is; by
+	// default they are escaped so every entry stays on one line and can't forge others
+	AllowControls bool
+	// ColorMode picks what is colored when LOG_COLOR_CONFIG sets a color for the level
+	ColorMode ColorMode
+	// NoColor leaves lines uncolored even when LOG_COLOR_CONFIG sets colors
+	NoColor bool
+	// CompactLevel writes a single-letter marker such as "E " in place of "ERROR - "
+	CompactLevel bool
+	// KeywordColors recolors these words in the message with their own color name, such
+	// as "RED", unless NoColor is set
+	KeywordColors map[string]string
+	// FieldOrder lists field keys written first, in this order; the rest follow sorted
+	FieldOrder []string
+}
+
+// flags returns the encoder's flags or DefaultFlags
+func (t TextEncoder) flags() int {
+	if t.Flags == 0 {
+		return DefaultFlags
+	}
+	return t.Flags
+}
+
+// Encode implements Encoder
+func (t TextEncoder) Encode(e Entry) []byte {
+	flags := t.flags()
+
+	var b bytes.Buffer
+	code := ""
+	if !t.NoColor {
+		code = levelColor(e.Level)
+	}
+	lineCode := ""
+	if t.ColorMode == ColorLine {
+		lineCode = code
+	}
+	b.WriteString(lineCode)
+
+	if flags&log.Lmsgprefix == 0 {
+		b.WriteString(t.Prefix)
+	}
+	textHeader(&b, flags, e)
+	if flags&log.Lmsgprefix != 0 {
+		b.WriteString(t.Prefix)
+	}
+	if e.Seq > 0 {
+		fmt.Fprintf(&b, "[%d] ", e.Seq)
+	}
+	if e.Component != "" {
+		b.WriteString(t.escape(e.Component) + ": ")
+	}
+	if !e.noPrefix {
+		if prefix := t.levelPrefix(e.Level); t.ColorMode == ColorLevel && code != "" && prefix != "" {
+			b.WriteString(code + prefix + colorReset)
+		} else {
+			b.WriteString(prefix)
+		}
+	}
+	b.WriteString(t.colorKeywords(t.escape(e.Message), lineCode))
+	if len(e.Tags) > 0 {
+		b.WriteString(" [" + t.escape(strings.Join(e.Tags, ",")) + "]")
+	}
+
+	for _, k := range orderedKeys(e.Fields, t.FieldOrder) {
+		b.WriteString(" " + t.escape(field(k, fmt.Sprint(e.Fields[k]))))
+	}
+
+	// in ColorLine mode the whole line, header included, sits inside the escape codes
+	if lineCode != "" {
+		b.WriteString(colorReset)
+	}
+	b.WriteByte('\n')
+
+	return b.Bytes()
+}
+
+// levelPrefix returns the verbose prefix for level, or its first letter with CompactLevel
+func (t TextEncoder) levelPrefix(level LogLevel) string {
+	prefix := levelPrefix(level)
+	if !t.CompactLevel || prefix == "" {
+		return prefix
+	}
+	return prefix[:1] + " "
+}
+
+// colorKeywords wraps each KeywordColors match in msg in its color, returning to restore,
+// the line's own color or none, after it; longer keywords win where they overlap
+func (t TextEncoder) colorKeywords(msg, restore string) string {
+	if t.NoColor || len(t.KeywordColors) == 0 {
+		return msg
+	}
+	if restore == "" {
+		restore = colorReset
+	}
+
+	words := make([]string, 0, len(t.KeywordColors))
+	for w := range t.KeywordColors {
+		if w != "" && colorCodes[colorEnum(t.KeywordColors[w])] != "" {
+			words = append(words, w)
+		}
+	}
+	sort.Slice(words, func(a, b int) bool {
+		if len(words[a]) != len(words[b]) {
+			return len(words[a]) > len(words[b])
+		}
+		return words[a] < words[b]
+	})
+	if len(words) == 0 {
+		return msg
+	}
+
+	var b strings.Builder
+	for len(msg) > 0 {
+		matched := false
+		for _, w := range words {
+			if strings.HasPrefix(msg, w) {
+				b.WriteString(colorCodes[colorEnum(t.KeywordColors[w])] + w + restore)
+				msg = msg[len(w):]
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			b.WriteByte(msg[0])
+			msg = msg[1:]
+		}
+	}
+
+	return b.String()
+}
+
+// escape replaces control characters other than tab with Go-style escapes unless AllowControls is set
+func (t TextEncoder) escape(s string) string {
+	if t.AllowControls || strings.IndexFunc(s, isControl) < 0 {
+		return s
+	}
+
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '\n':
+			b.WriteString(`\n`)
+		case r == '\r':
+			b.WriteString(`\r`)
+		case isControl(r):
+			fmt.Fprintf(&b, `\x%02x`, r)
+		default:
+			b.WriteRune(r)
+		}
+	}
+
+	return b.String()
+}
+
+// isControl reports whether r is an ASCII control character that could break a text line
+func isControl(r rune) bool {
+	return (r < 0x20 && r != '\t') || r == 0x7f
+}
+
+// textHeader renders the timestamp and caller the way log.Logger does for flags
+func textHeader(b *bytes.Buffer, flags int, e Entry) {
+	t := e.Time
+	if flags&log.LUTC != 0 {
+		t = t.UTC()
+	} else {
+		t = t.Local()
+	}
+
+	if flags&log.Ldate != 0 {
+		b.WriteString(t.Format("2006/01/02 "))
+	}
+	if flags&(log.Ltime|log.Lmicroseconds) != 0 {
+		b.WriteString(t.Format("15:04:05"))
+		if flags&log.Lmicroseconds != 0 {
+			b.WriteString(t.Format(".000000"))
+		}
+		b.WriteByte(' ')
+	}
+	if flags&(log.Lshortfile|log.Llongfile) != 0 || e.callerSet {
+		caller := e.Caller
+		if caller == "" {
+			caller = "???:0"
+		} else if flags&log.Lshortfile != 0 {
+			caller = filepath.Base(caller)
+		}
+		b.WriteString(caller + ": ")
+	}
+}
+
+// truncatedMarker replaces the tail of a field value cut by MaxFieldBytes
+const truncatedMarker = "...(truncated)"
+
+// Timestamp precisions for JSON output
+const (
+	TimeMillis = TimePrecision(iota)
+	TimeSeconds
+	TimeMicros
+	TimeNanos
+)
+
+// TimePrecision is the number of fractional digits kept in a JSON timestamp
+type TimePrecision uint8
+
+// digits returns the fractional second digits for the precision
+func (p TimePrecision) digits() int {
+	switch p {
+	case TimeSeconds:
+		return 0
+	case TimeMicros:
+		return 6
+	case TimeNanos:
+		return 9
+	default:
+		return 3
+	}
+}
+
+// Timestamp representations for JSON output
+const (
+	// TimeRFC3339 writes the time as an RFC 3339 string in UTC
+	TimeRFC3339 = TimeFormat(iota)
+	// TimeEpoch writes the time as a number of seconds since the Unix epoch
+	TimeEpoch
+)
+
+// TimeFormat is how a JSON encoder represents the entry time
+type TimeFormat uint8
+
+// JSONEncoder renders each entry as a single JSON object
+type JSONEncoder struct {
+	// MaxFieldBytes cuts string field values longer than this, marking them truncated; 0 disables it
+	MaxFieldBytes int
+	// TimePrecision picks the fractional seconds kept in the timestamp; defaults to milliseconds
+	TimePrecision TimePrecision
+	// TimeFormat picks an RFC 3339 string (the default) or epoch seconds for the timestamp
+	TimeFormat TimeFormat
+}
+
+// timestamp renders t with the encoder's precision and representation; fractions are
+// truncated, not rounded, and epoch seconds are exact decimals rather than float64
+func (j JSONEncoder) timestamp(t time.Time) interface{} {
+	t = t.UTC()
+	digits := j.TimePrecision.digits()
+
+	if j.TimeFormat == TimeEpoch {
+		n := strconv.FormatInt(t.Unix(), 10)
+		if digits > 0 {
+			n += fmt.Sprintf(".%09d", t.Nanosecond())[:digits+1]
+		}
+		return json.Number(n)
+	}
+
+	layout := "2006-01-02T15:04:05Z07:00"
+	if digits > 0 {
+		layout = "2006-01-02T15:04:05." + strings.Repeat("0", digits) + "Z07:00"
+	}
+	return t.Format(layout)
+}
+
+// jsonNames are the keys an encoder uses for the standard entry fields
+type jsonNames struct {
+	time, level, msg, caller, labels string
+}
+
+var (
+	defaultJSONNames = jsonNames{time: "time", level: "level", msg: "msg", caller: "caller", labels: "labels"}
+	cloudJSONNames   = jsonNames{time: "timestamp", level: "severity", msg: "message", caller: "logging.googleapis.com/sourceLocation", labels: "logging.googleapis.com/labels"}
+)
+
+// Encode implements Encoder
+func (j JSONEncoder) Encode(e Entry) []byte {
+	var caller interface{}
+	if e.Caller != "" {
+		caller = e.Caller
+	}
+	return j.encode(e, defaultJSONNames, e.Level.String(), caller)
+}
+
+// encode writes the entry as one JSON object under names, with level and caller already
+// rendered; a nil caller is left out
+func (j JSONEncoder) encode(e Entry, names jsonNames, level string, caller interface{}) []byte {
+	var b bytes.Buffer
+	b.WriteByte('{')
+
+	writeJSON(&b, names.time, j.timestamp(e.Time))
+	b.WriteByte(',')
+	writeJSON(&b, names.level, level)
+	b.WriteByte(',')
+	writeJSON(&b, names.msg, e.Message)
+	if caller != nil {
+		b.WriteByte(',')
+		writeJSON(&b, names.caller, caller)
+	}
+	if e.Component != "" {
+		b.WriteByte(',')
+		writeJSON(&b, "component", e.Component)
+	}
+	if e.Seq > 0 {
+		b.WriteByte(',')
+		writeJSON(&b, "seq", e.Seq)
+	}
+	if len(e.Tags) > 0 {
+		b.WriteByte(',')
+		writeJSON(&b, "tags", e.Tags)
+	}
+	if len(e.Labels) > 0 {
+		b.WriteByte(',')
+		writeJSON(&b, names.labels, e.Labels)
+	}
+
+	for _, k := range sortedKeys(e.Fields) {
+		b.WriteByte(',')
+		writeJSON(&b, k, j.limit(e.Fields[k]))
+	}
+
+	b.WriteString("}\n")
+
+	return b.Bytes()
+}
+
+// CloudLoggingEncoder renders entries as the JSON that Google Cloud Logging parses from a
+// container's output: "timestamp", "severity" and "message", with the caller as a source location
+type CloudLoggingEncoder struct {
+	JSONEncoder
+}
+
+// cloudSeverities maps the standard levels to Cloud Logging severities; others are DEFAULT
+var cloudSeverities = map[LogLevel]string{
+	LMandatory: "NOTICE",
+	LError:     "ERROR",
+	LWarn:      "WARNING",
+	LInfo:      "INFO",
+	LDebug:     "DEBUG",
+}
+
+// sourceLocation is Cloud Logging's LogEntrySourceLocation; line is a string in its JSON form
+type sourceLocation struct {
+	File string `json:"file"`
+	Line string `json:"line"`
+}
+
+// Encode implements Encoder
+func (c CloudLoggingEncoder) Encode(e Entry) []byte {
+	severity, ok := cloudSeverities[e.Level]
+	if !ok {
+		severity = "DEFAULT"
+	}
+
+	var caller interface{}
+	if e.Caller != "" {
+		loc := sourceLocation{File: e.Caller}
+		if n := strings.LastIndexByte(e.Caller, ':'); n >= 0 {
+			loc = sourceLocation{File: e.Caller[:n], Line: e.Caller[n+1:]}
+		}
+		caller = loc
+	}
+
+	return c.encode(e, cloudJSONNames, severity, caller)
+}
+
+// CSVEncoder renders entries as CSV rows of time, level, caller, message and fields, always
+// five columns so every row lines up with the header; the component, sequence number,
+// tags and fields share the last column as space-separated key=value pairs
+type CSVEncoder struct{}
+
+// FileHeader implements HeaderEncoder with the column names
+func (CSVEncoder) FileHeader() []byte {
+	return csvRecord([]string{"time", "level", "caller", "message", "fields"})
+}
+
+// Encode implements Encoder
+func (CSVEncoder) Encode(e Entry) []byte {
+	var fields []string
+	if e.Component != "" {
+		fields = append(fields, field("component", e.Component))
+	}
+	if e.Seq > 0 {
+		fields = append(fields, field("seq", strconv.FormatUint(e.Seq, 10)))
+	}
+	if len(e.Tags) > 0 {
+		fields = append(fields, field("tags", strings.Join(e.Tags, ",")))
+	}
+	for _, k := range sortedKeys(e.Fields) {
+		fields = append(fields, field(k, fmt.Sprint(e.Fields[k])))
+	}
+
+	return csvRecord([]string{e.Time.UTC().Format("2006-01-02T15:04:05.000Z07:00"), e.Level.String(), e.Caller, e.Message, strings.Join(fields, " ")})
+}
+
+// csvRecord writes one CSV row with encoding/csv's quoting
+func csvRecord(record []string) []byte {
+	var b bytes.Buffer
+	w := csv.NewWriter(&b)
+	w.Write(record)
+	w.Flush()
+
+	return b.Bytes()
+}
+
+// limit applies MaxFieldBytes to string and error field values
+func (j JSONEncoder) limit(value interface{}) interface{} {
+	if j.MaxFieldBytes <= 0 {
+		return value
+	}
+
+	var s string
+	switch v := value.(type) {
+	case string:
+		s = v
+	case error:
+		s = v.Error()
+	default:
+		return value
+	}
+	if len(s) <= j.MaxFieldBytes {
+		return value
+	}
+
+	// back up to a rune boundary so the cut value stays valid UTF-8
+	n := j.MaxFieldBytes
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n] + truncatedMarker
+}
+
+// formatName names an encoder's format for the file header
+func formatName(enc Encoder) string {
+	switch enc.(type) {
+	case TextEncoder, *TextEncoder:
+		return "text"
+	case JSONEncoder, *JSONEncoder:
+		return "json"
+	case CloudLoggingEncoder, *CloudLoggingEncoder:
+		return "cloudlogging"
+	case CSVEncoder, *CSVEncoder:
+		return "csv"
+	default:
+		return fmt.Sprintf("%T", enc)
+	}
+}
+
+// writeJSON writes "key":value, falling back to the value's %v text when it can't be marshaled
+func writeJSON(b *bytes.Buffer, key string, value interface{}) {
+	k, _ := json.Marshal(key)
+	b.Write(k)
+	b.WriteByte(':')
+
+	if err, ok := value.(error); ok {
+		value = err.Error()
+	}
+
+	v, err := json.Marshal(value)
+	if err != nil {
+		v, _ = json.Marshal(fmt.Sprintf("%+v", value))
+	}
+	b.Write(v)
+}
+
+// orderedKeys returns the keys of fields named in order first, in that order, followed by
+// the others sorted
+func orderedKeys(fields map[string]interface{}, order []string) []string {
+	if len(order) == 0 {
+		return sortedKeys(fields)
+	}
+
+	keys := make([]string, 0, len(fields))
+	placed := make(map[string]bool, len(order))
+	for _, k := range order {
+		if _, ok := fields[k]; ok && !placed[k] {
+			keys = append(keys, k)
+			placed[k] = true
+		}
+	}
+	for _, k := range sortedKeys(fields) {
+		if !placed[k] {
+			keys = append(keys, k)
+		}
+	}
+
+	return keys
+}
+
+// sortedKeys returns the keys of fields in a stable order
+func sortedKeys(fields map[string]interface{}) []string {
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
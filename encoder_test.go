@@ -0,0 +1,319 @@
+package ilogger
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testTime is a fixed entry time for encoder tests
+var testTime = time.Date(2023, 5, 10, 14, 3, 7, 123456789, time.UTC)
+
+func TestColorLineWrapsWholeLine(t *testing.T) {
+	colorConfig(t, "- level: WARN\n  color: YELLOW\n")
+
+	enc := TextEncoder{Flags: log.LstdFlags | log.LUTC | log.Lshortfile}
+	got := string(enc.Encode(Entry{Time: testTime, Level: LWarn, Message: "disk at 91%", Caller: "/src/app/disk.go:42"}))
+
+	want := colorCodes[yellowEnum] + "2023/05/10 14:03:07 disk.go:42: WARN - disk at 91%" + colorReset + "\n"
+	if got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestColorLevelColorsOnlyPrefix(t *testing.T) {
+	colorConfig(t, "- level: ERROR\n  color: RED\n")
+
+	enc := TextEncoder{Flags: log.LstdFlags | log.LUTC, ColorMode: ColorLevel}
+	got := string(enc.Encode(Entry{Time: testTime, Level: LError, Message: "failed", Component: "db", Fields: map[string]interface{}{"id": 7}}))
+
+	want := "2023/05/10 14:03:07 db: " + colorCodes[redEnum] + "ERROR - " + colorReset + "failed id=7\n"
+	if got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	// levels without a configured color and NoColor stay plain in either mode
+	for _, enc := range []TextEncoder{
+		{Flags: log.Lmsgprefix, ColorMode: ColorLevel},
+		{Flags: log.Lmsgprefix, ColorMode: ColorLine},
+	} {
+		if got := string(enc.Encode(Entry{Level: LInfo, Message: "plain"})); got != "INFO - plain\n" {
+			t.Errorf("%v: uncolored level got %q", enc.ColorMode, got)
+		}
+		enc.NoColor = true
+		if got := string(enc.Encode(Entry{Level: LError, Message: "plain"})); got != "ERROR - plain\n" {
+			t.Errorf("%v: NoColor got %q", enc.ColorMode, got)
+		}
+	}
+}
+
+func TestCompactLevel(t *testing.T) {
+	colorConfig(t, "- level: ERROR\n  color: RED\n")
+
+	enc := TextEncoder{Flags: log.Lmsgprefix, CompactLevel: true, NoColor: true}
+	for level, want := range map[LogLevel]string{
+		LError:     "E failed\n",
+		LWarn:      "W failed\n",
+		LInfo:      "I failed\n",
+		LDebug:     "D failed\n",
+		LMandatory: "failed\n",
+	} {
+		if got := string(enc.Encode(Entry{Level: level, Message: "failed"})); got != want {
+			t.Errorf("%v: got %q, want %q", level, got, want)
+		}
+	}
+
+	// the prefix color wraps the marker alone
+	enc = TextEncoder{Flags: log.Lmsgprefix, CompactLevel: true, ColorMode: ColorLevel}
+	if got, want := string(enc.Encode(Entry{Level: LError, Message: "failed"})), colorCodes[redEnum]+"E "+colorReset+"failed\n"; got != want {
+		t.Errorf("colored: got %q, want %q", got, want)
+	}
+}
+
+func TestFieldOrderStable(t *testing.T) {
+	fields := map[string]interface{}{"user": "ann", "zone": "eu", "attempt": 2, "id": 7, "cost": 1.5}
+
+	tests := []struct {
+		order []string
+		want  string
+	}{
+		{nil, "INFO - saved attempt=2 cost=1.5 id=7 user=ann zone=eu\n"},
+		{[]string{"id", "missing", "user", "id"}, "INFO - saved id=7 user=ann attempt=2 cost=1.5 zone=eu\n"},
+	}
+	for _, tt := range tests {
+		enc := TextEncoder{Flags: log.Lmsgprefix, FieldOrder: tt.order}
+		for n := 0; n < 100; n++ {
+			// a fresh map each time gets a fresh iteration order
+			e := Entry{Level: LInfo, Message: "saved", Fields: map[string]interface{}{}}
+			for k, v := range fields {
+				e.Fields[k] = v
+			}
+			if got := string(enc.Encode(e)); got != tt.want {
+				t.Fatalf("order %v, run %d: got %q, want %q", tt.order, n, got, tt.want)
+			}
+		}
+	}
+}
+
+func TestKeywordColors(t *testing.T) {
+	colorConfig(t, "- level: WARN\n  color: YELLOW\n")
+	keywords := map[string]string{"FAILED": "RED", "timeout": "cyan", "ignored": "PLAID"}
+	yellow, red, cyan := colorCodes[yellowEnum], colorCodes[redEnum], colorCodes[cyanEnum]
+
+	enc := TextEncoder{Flags: log.Lmsgprefix, KeywordColors: keywords}
+	got := string(enc.Encode(Entry{Level: LWarn, Message: "job FAILED after timeout, ignored"}))
+	want := yellow + "WARN - job " + red + "FAILED" + yellow + " after " + cyan + "timeout" + yellow + ", ignored" + colorReset + "\n"
+	if got != want {
+		t.Errorf("ColorLine: got %q, want %q", got, want)
+	}
+
+	// outside a colored line a keyword returns to plain text
+	enc.ColorMode = ColorLevel
+	got = string(enc.Encode(Entry{Level: LWarn, Message: "FAILED"}))
+	if want := yellow + "WARN - " + colorReset + red + "FAILED" + colorReset + "\n"; got != want {
+		t.Errorf("ColorLevel: got %q, want %q", got, want)
+	}
+
+	enc.NoColor = true
+	if got := string(enc.Encode(Entry{Level: LWarn, Message: "FAILED"})); got != "WARN - FAILED\n" {
+		t.Errorf("NoColor: got %q", got)
+	}
+}
+
+func TestKeywordColorsOnlyOnTerminal(t *testing.T) {
+	colorConfig(t, "- level: WARN\n  color: YELLOW\n")
+
+	// ColorAlways forces the level color into the file, but keywords stay terminal only
+	l := newTestLogger(t, &ILog{Flags: log.Lmsgprefix, Color: ColorAlways, KeywordColors: map[string]string{"FAILED": "RED"}})
+	l.Warn("job FAILED")
+	if got, want := readLog(t, l), colorCodes[yellowEnum]+"WARN - job FAILED"+colorReset+"\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+// upperEncoder is a custom Encoder writing the level and message in upper case
+type upperEncoder struct{}
+
+func (upperEncoder) Encode(e Entry) []byte {
+	return []byte(strings.ToUpper(e.Level.String()+"|"+e.Message) + "\n")
+}
+
+func TestCustomEncoder(t *testing.T) {
+	l := newTestLogger(t, &ILog{Encoder: upperEncoder{}})
+	l.Info("user %s signed in", "ann")
+	l.Errorf("quota exceeded")
+
+	if got, want := readLog(t, l), "INFO|USER ANN SIGNED IN\nERROR|QUOTA EXCEEDED\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestJSONEncoderEntry(t *testing.T) {
+	got := string(JSONEncoder{}.Encode(Entry{
+		Time:    testTime,
+		Level:   LWarn,
+		Message: "slow \"query\"",
+		Caller:  "/src/app/db.go:12",
+		Fields:  map[string]interface{}{"rows": 3, "table": "orders"},
+	}))
+
+	want := `{"time":"2023-05-10T14:03:07.123Z","level":"WARN","msg":"slow \"query\"","caller":"/src/app/db.go:12","rows":3,"table":"orders"}` + "\n"
+	if got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
+
+func TestCloudLoggingEncoder(t *testing.T) {
+	got := string(CloudLoggingEncoder{}.Encode(Entry{
+		Time:    testTime,
+		Level:   LWarn,
+		Message: "quota low",
+		Caller:  "/src/app/quota.go:31",
+		Fields:  map[string]interface{}{"remaining": 5},
+	}))
+
+	want := `{"timestamp":"2023-05-10T14:03:07.123Z","severity":"WARNING","message":"quota low",` +
+		`"logging.googleapis.com/sourceLocation":{"file":"/src/app/quota.go","line":"31"},"remaining":5}` + "\n"
+	if got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+
+	severities := map[LogLevel]string{
+		LMandatory:  "NOTICE",
+		LError:      "ERROR",
+		LWarn:       "WARNING",
+		LInfo:       "INFO",
+		LDebug:      "DEBUG",
+		LogLevel(6): "DEFAULT",
+	}
+	for level, want := range severities {
+		var e map[string]interface{}
+		if err := json.Unmarshal(CloudLoggingEncoder{}.Encode(Entry{Level: level}), &e); err != nil {
+			t.Fatal(err)
+		}
+		if e["severity"] != want {
+			t.Errorf("%v: severity = %v, want %s", level, e["severity"], want)
+		}
+	}
+}
+
+func TestJSONTimestamps(t *testing.T) {
+	tests := []struct {
+		precision TimePrecision
+		format    TimeFormat
+		want      string
+	}{
+		{TimeMillis, TimeRFC3339, `"2023-05-10T14:03:07.123Z"`},
+		{TimeSeconds, TimeRFC3339, `"2023-05-10T14:03:07Z"`},
+		{TimeMicros, TimeRFC3339, `"2023-05-10T14:03:07.123456Z"`},
+		{TimeNanos, TimeRFC3339, `"2023-05-10T14:03:07.123456789Z"`},
+		{TimeMillis, TimeEpoch, `1683727387.123`},
+		{TimeSeconds, TimeEpoch, `1683727387`},
+		{TimeMicros, TimeEpoch, `1683727387.123456`},
+		{TimeNanos, TimeEpoch, `1683727387.123456789`},
+	}
+	for _, tt := range tests {
+		enc := JSONEncoder{TimePrecision: tt.precision, TimeFormat: tt.format}
+		at := testTime.In(time.FixedZone("CEST", 2*3600))
+		got := string(enc.Encode(Entry{Time: at, Level: LInfo, Message: "m"}))
+		if want := `{"time":` + tt.want + `,`; !strings.HasPrefix(got, want) {
+			t.Errorf("precision %d, format %d: got %s, want prefix %s", tt.precision, tt.format, got, want)
+		}
+	}
+}
+
+func TestTrailingErrorField(t *testing.T) {
+	err := fmt.Errorf("read manifest: %w", os.ErrPermission)
+
+	j := newTestLogger(t, &ILog{Encoder: JSONEncoder{}})
+	j.Warn("deploy failed: %v", err)
+	e := jsonEntries(t, j)[0]
+	if e["error"] != "read manifest: permission denied" {
+		t.Errorf("error field = %v", e["error"])
+	}
+	if e["msg"] != "deploy failed: read manifest: permission denied" {
+		t.Errorf("msg = %v", e["msg"])
+	}
+
+	// text keeps the chain in the message alone
+	l := newTestLogger(t, &ILog{Flags: log.Lmsgprefix})
+	l.Warn("deploy failed: %v", err)
+	if got := readLog(t, l); got != "WARN - deploy failed: read manifest: permission denied\n" {
+		t.Errorf("text line = %q", got)
+	}
+}
+
+func TestMaxFieldBytes(t *testing.T) {
+	enc := JSONEncoder{MaxFieldBytes: 8}
+	line := enc.Encode(Entry{
+		Time:    testTime,
+		Level:   LInfo,
+		Message: "a message longer than the field limit",
+		Fields: map[string]interface{}{
+			"blob":  "aGVsbG8gd29ybGQ=",
+			"short": "ok",
+			"runes": "ééééé", // 10 bytes, cut back to a rune boundary
+			"count": 123456789,
+		},
+	})
+
+	var e map[string]interface{}
+	if err := json.Unmarshal(line, &e); err != nil {
+		t.Fatalf("invalid JSON %s: %v", line, err)
+	}
+	want := map[string]interface{}{
+		"msg":   "a message longer than the field limit",
+		"blob":  "aGVsbG8g" + truncatedMarker,
+		"short": "ok",
+		"runes": "éééé" + truncatedMarker,
+		"count": 123456789.0,
+	}
+	for k, v := range want {
+		if e[k] != v {
+			t.Errorf("%s = %v, want %v", k, e[k], v)
+		}
+	}
+}
+
+func TestCSVEncoder(t *testing.T) {
+	l := newTestLogger(t, &ILog{Encoder: CSVEncoder{}, Component: "billing", Sequence: true, Tags: []string{"eu", "db"}})
+	l.Info(`total "due", incl. tax`)
+	l.InfoFields("charged\nann", String("note", "a, \"b\""), Int("cents", 250))
+	l.LogBatch([]Entry{{Level: LWarn, Message: "plain"}})
+
+	r := csv.NewReader(strings.NewReader(readLog(t, l)))
+	r.FieldsPerRecord = 5
+	rows, err := r.ReadAll()
+	if err != nil {
+		t.Fatalf("output is not valid 5-column CSV: %v", err)
+	}
+	if len(rows) != 4 {
+		t.Fatalf("got %d rows, want the header and 3 entries: %q", len(rows), rows)
+	}
+	if want := []string{"time", "level", "caller", "message", "fields"}; !reflect.DeepEqual(rows[0], want) {
+		t.Errorf("header = %q, want %q", rows[0], want)
+	}
+
+	want := [][]string{
+		{"INFO", `total "due", incl. tax`, `component=billing seq=1 tags=eu,db`},
+		{"INFO", "charged\nann", `component=billing seq=2 tags=eu,db cents=250 note="a, \"b\""`},
+		{"WARN", "plain", `component=billing seq=3 tags=eu,db`},
+	}
+	for n, row := range rows[1:] {
+		if _, err := time.Parse(time.RFC3339Nano, row[0]); err != nil {
+			t.Errorf("row %d time %q: %v", n, row[0], err)
+		}
+		if got := []string{row[1], row[3], row[4]}; !reflect.DeepEqual(got, want[n]) {
+			t.Errorf("row %d = %q, want %q", n, got, want[n])
+		}
+	}
+	if !strings.Contains(rows[1][2], "encoder_test.go:") {
+		t.Errorf("caller = %q, want this file", rows[1][2])
+	}
+}
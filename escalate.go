@@ -0,0 +1,71 @@
+package ilogger
+
+import (
+	"sync"
+	"time"
+)
+
+// Escalation raises the level of a message that keeps repeating, e.g. a warning that
+// turns out to be persistent rather than transient
+type Escalation struct {
+	// From is the level whose messages are counted
+	From LogLevel
+	// To is the level written from the After-th repeat on
+	To LogLevel
+	// After is how many times the same message must occur within Window; 0 disables it
+	After int
+	// Window is the period the occurrences are counted over, starting from the first
+	Window time.Duration
+}
+
+// escalateCount tracks one distinct message within Escalation.Window
+type escalateCount struct {
+	start time.Time
+	count int
+}
+
+// escalator holds the state behind ILog.Escalation
+type escalator struct {
+	mu   sync.Mutex
+	seen map[uint64]*escalateCount
+}
+
+// escalate applies the Escalation policy to entries at its From level, keyed on the
+// rendered message. Only entries that passed the level filter at From are counted.
+func (i *ILog) escalate(entries []Entry) {
+	p := i.Escalation
+	now := time.Now()
+
+	i.escalator.mu.Lock()
+	defer i.escalator.mu.Unlock()
+
+	if i.escalator.seen == nil {
+		i.escalator.seen = make(map[uint64]*escalateCount)
+	}
+
+	for n, e := range entries {
+		if e.Level != p.From {
+			continue
+		}
+
+		key := dedupKey(e.Level, e.Message)
+		c, ok := i.escalator.seen[key]
+		if !ok || now.Sub(c.start) >= p.Window {
+			c = &escalateCount{start: now}
+			i.escalator.seen[key] = c
+		}
+		c.count++
+
+		if c.count >= p.After {
+			entries[n].Level = p.To
+		}
+	}
+
+	if len(i.escalator.seen) > dedupSweepSize {
+		for key, c := range i.escalator.seen {
+			if now.Sub(c.start) >= p.Window {
+				delete(i.escalator.seen, key)
+			}
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package ilogger
+
+import (
+	"log"
+	"testing"
+	"time"
+)
+
+func TestEscalation(t *testing.T) {
+	l := newTestLogger(t, &ILog{Flags: log.Lmsgprefix, Escalation: Escalation{From: LWarn, To: LError, After: 3, Window: time.Hour}})
+
+	for n := 0; n < 4; n++ {
+		l.Warn("replica lagging")
+		if n == 1 {
+			l.Warn("cache cold")
+		}
+	}
+	l.Info("replica lagging")
+
+	want := "WARN - replica lagging\n" +
+		"WARN - replica lagging\n" +
+		"WARN - cache cold\n" +
+		"ERROR - replica lagging\n" +
+		"ERROR - replica lagging\n" +
+		"INFO - replica lagging\n"
+	if got := readLog(t, l); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestEscalationWindow(t *testing.T) {
+	l := newTestLogger(t, &ILog{Flags: log.Lmsgprefix, Escalation: Escalation{From: LWarn, To: LError, After: 2, Window: 50 * time.Millisecond}})
+
+	l.Warn("slow")
+	time.Sleep(60 * time.Millisecond)
+	// the window has passed, so the count starts over
+	l.Warn("slow")
+	l.Warn("slow")
+
+	if got, want := readLog(t, l), "WARN - slow\nWARN - slow\nERROR - slow\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
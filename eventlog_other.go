@@ -0,0 +1,24 @@
+//go:build !windows
+// +build !windows
+
+package ilogger
+
+import "errors"
+
+// EventLogSink writes entries to the Windows Event Log; it is only available on windows
+type EventLogSink struct{}
+
+// NewEventLogSink always fails outside windows
+func NewEventLogSink(source string) (*EventLogSink, error) {
+	return nil, errors.New("the event log is only supported on windows")
+}
+
+// WriteEntry implements Sink
+func (s *EventLogSink) WriteEntry(e Entry) error {
+	return errors.New("the event log is only supported on windows")
+}
+
+// Close implements io.Closer
+func (s *EventLogSink) Close() error {
+	return nil
+}
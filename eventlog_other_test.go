@@ -0,0 +1,12 @@
+//go:build !windows
+// +build !windows
+
+package ilogger
+
+import "testing"
+
+func TestEventLogUnavailable(t *testing.T) {
+	if _, err := NewEventLogSink("app"); err == nil {
+		t.Error("NewEventLogSink succeeded outside windows")
+	}
+}
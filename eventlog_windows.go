@@ -0,0 +1,62 @@
+package ilogger
+
+import (
+	"fmt"
+
+	"golang.org/x/sys/windows/svc/eventlog"
+)
+
+// eventID is the event ID reported for every entry
+const eventID = 1
+
+// EventLogSink writes entries to the Windows Event Log
+type EventLogSink struct {
+	log *eventlog.Log
+}
+
+// NewEventLogSink opens the event log for source, which must already be registered
+// (e.g. with eventlog.InstallAsEventCreate)
+func NewEventLogSink(source string) (*EventLogSink, error) {
+	l, err := eventlog.Open(source)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open event log (%s): %w", source, err)
+	}
+
+	return &EventLogSink{log: l}, nil
+}
+
+// WriteEntry implements Sink
+func (s *EventLogSink) WriteEntry(e Entry) error {
+	msg := e.Message
+	if e.Component != "" {
+		msg = e.Component + ": " + msg
+	}
+
+	switch eventType(e.Level) {
+	case eventlog.Error:
+		return s.log.Error(eventID, msg)
+	case eventlog.Warning:
+		return s.log.Warning(eventID, msg)
+	default:
+		return s.log.Info(eventID, msg)
+	}
+}
+
+// Close closes the event log
+func (s *EventLogSink) Close() error {
+	return s.log.Close()
+}
+
+// eventType maps a level to an event type; Mandatory and Info and below are informational
+func eventType(level LogLevel) uint16 {
+	switch {
+	case level == LMandatory:
+		return eventlog.Info
+	case level <= LError:
+		return eventlog.Error
+	case level <= LWarn:
+		return eventlog.Warning
+	default:
+		return eventlog.Info
+	}
+}
@@ -0,0 +1,25 @@
+package ilogger
+
+import (
+	"testing"
+
+	"golang.org/x/sys/windows/svc/eventlog"
+)
+
+func TestEventType(t *testing.T) {
+	tests := []struct {
+		level LogLevel
+		want  uint16
+	}{
+		{LMandatory, eventlog.Info},
+		{LError, eventlog.Error},
+		{LWarn, eventlog.Warning},
+		{LInfo, eventlog.Info},
+		{LDebug, eventlog.Info},
+	}
+	for _, tt := range tests {
+		if got := eventType(tt.level); got != tt.want {
+			t.Errorf("eventType(%v) = %d, want %d", tt.level, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package ilogger
+
+import (
+	"log"
+	"os"
+	"os/signal"
+	"runtime"
+	"sync"
+	"syscall"
+	"time"
+)
+
+var (
+	// exitMu guards the registries below
+	exitMu sync.Mutex
+
+	// exitSignals syncs its loggers when the process gets SIGINT or SIGTERM
+	exitSignals = &signalRegistry{sigs: []os.Signal{os.Interrupt, syscall.SIGTERM}, loggers: map[*ILog]bool{}}
+	// quitSignals dumps the goroutine stacks to its loggers when the process gets SIGQUIT
+	quitSignals = &signalRegistry{sigs: []os.Signal{syscall.SIGQUIT}, loggers: map[*ILog]bool{}}
+)
+
+// signalRegistry is the loggers handling sigs, each with whether it wants the signal
+// raised again, and the channel the handler reads while any are registered
+type signalRegistry struct {
+	sigs    []os.Signal
+	loggers map[*ILog]bool
+	once    sync.Once
+	ch      chan os.Signal
+}
+
+// FlushOnExit registers the logger to be synced, flushing WriteBufferBytes and any
+// scoped outputs, when the process receives SIGINT or SIGTERM; the signal is then
+// raised again with the default handling restored, so the process ends as it would
+// have. Go has no atexit, so a plain return from main or os.Exit still needs Close or Sync.
+//
+// An application with a handler of its own for these signals sets NoReraise, so its
+// handler isn't cut short; the signal is still raised again if any registered logger
+// lacks NoReraise. Once every logger has unregistered the signals are released.
+func (i *ILog) FlushOnExit() (unregister func()) {
+	return register(exitSignals, i, !i.NoReraise)
+}
+
+// DumpOnQuit registers the logger to write a Mandatory entry holding every goroutine's
+// stack as its "stack" field, then sync, when the process receives SIGQUIT; the signal
+// is then raised again, so Go's own dump and exit still follow. With NoReraise the
+// process carries on instead, e.g. to take a dump of a stuck process without ending it.
+func (i *ILog) DumpOnQuit() (unregister func()) {
+	return register(quitSignals, i, !i.NoReraise)
+}
+
+// register adds i to r, starting the handler for r's signals the first time; the last
+// logger to unregister stops it, so a later register starts it again
+func register(r *signalRegistry, i *ILog, reraise bool) (unregister func()) {
+	exitMu.Lock()
+	defer exitMu.Unlock()
+
+	r.loggers[i] = reraise
+	r.once.Do(func() {
+		r.ch = make(chan os.Signal, 1)
+		signal.Notify(r.ch, r.sigs...)
+		go handleSignals(r, r.ch)
+	})
+
+	return func() {
+		exitMu.Lock()
+		defer exitMu.Unlock()
+
+		if _, ok := r.loggers[i]; !ok {
+			return
+		}
+		delete(r.loggers, i)
+		if len(r.loggers) == 0 {
+			signal.Stop(r.ch)
+			close(r.ch)
+			r.ch = nil
+			r.once = sync.Once{}
+		}
+	}
+}
+
+// handleSignals dumps stacks for SIGQUIT and syncs r's loggers on each signal until ch
+// is closed, re-raising it with the default handling restored once a logger asks for that
+func handleSignals(r *signalRegistry, ch chan os.Signal) {
+	for sig := range ch {
+		if flushOnSignal(r, sig) {
+			signal.Reset(r.sigs...)
+			p, err := os.FindProcess(os.Getpid())
+			if err == nil {
+				err = p.Signal(sig)
+			}
+			if err != nil {
+				// platforms that can't signal themselves still exit
+				os.Exit(1)
+			}
+			return
+		}
+	}
+}
+
+// flushOnSignal runs r's loggers' handling of sig, reporting whether any of them wants
+// it raised again
+func flushOnSignal(r *signalRegistry, sig os.Signal) (reraise bool) {
+	exitMu.Lock()
+	defer exitMu.Unlock()
+
+	if sig == syscall.SIGQUIT {
+		stack := allStacks()
+		for l := range r.loggers {
+			l.dumpStacks(stack)
+		}
+	}
+	for l, again := range r.loggers {
+		if err := l.Sync(); err != nil {
+			log.Printf("unable to flush logger on %v: %+v", sig, err)
+		}
+		reraise = reraise || again
+	}
+
+	return reraise
+}
+
+// dumpStacks writes stack as a Mandatory entry
+func (i *ILog) dumpStacks(stack string) {
+	if !i.enabled(LMandatory) {
+		return
+	}
+
+	e := i.entry(1, time.Now(), LMandatory, false, "SIGQUIT goroutine dump", nil)
+	e.Fields = withField(e.Fields, "stack", stack)
+	i.write(e)
+}
+
+// allStacks returns the stacks of every goroutine, growing the buffer until they fit
+func allStacks() string {
+	buf := make([]byte, 64<<10)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			return string(buf[:n])
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+}
@@ -0,0 +1,220 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+// +build darwin dragonfly freebsd linux netbsd openbsd
+
+package ilogger
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// signalChildEnv makes TestSignalChild run as the process that receives the signal,
+// in the mode named by its value, logging into the directory in signalDirEnv
+const (
+	signalChildEnv = "ILOGGER_TEST_SIGNAL_CHILD"
+	signalDirEnv   = "ILOGGER_TEST_SIGNAL_DIR"
+)
+
+// TestSignalChild is only run as a subprocess by runSignalChild
+func TestSignalChild(t *testing.T) {
+	mode := os.Getenv(signalChildEnv)
+	if mode == "" {
+		t.Skip("run as a subprocess only")
+	}
+	dir := os.Getenv(signalDirEnv)
+
+	l, err := FromStruct(&ILog{Path: dir, Level: LInfo, Flags: log.Lmsgprefix, WriteBufferBytes: 64 << 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	switch mode {
+	case "exit":
+		l.FlushOnExit()
+	case "exit-unregistered":
+		unregister := l.FlushOnExit()
+		unregister()
+	case "exit-app-handler":
+		l.NoReraise = true
+		l.FlushOnExit()
+		ch := make(chan os.Signal, 1)
+		signal.Notify(ch, syscall.SIGTERM)
+		go func() {
+			<-ch
+			// the application's own cleanup, which re-raising would cut short
+			time.Sleep(200 * time.Millisecond)
+			ioutil.WriteFile(filepath.Join(dir, "app-handler"), []byte("done"), 0644)
+			os.Exit(0)
+		}()
+	case "quit":
+		l.DumpOnQuit()
+	case "quit-continue":
+		l.NoReraise = true
+		l.DumpOnQuit()
+	}
+
+	l.Info("buffered before the signal")
+	fmt.Println("ready")
+	time.Sleep(10 * time.Second)
+	os.Exit(3)
+}
+
+// runSignalChild starts TestSignalChild in mode, sends it sig once it is ready and returns
+// its log directory, its stderr and how it ended
+func runSignalChild(t *testing.T, mode string, sig os.Signal, wait time.Duration) (dir, stderr string, err error) {
+	t.Helper()
+
+	dir = t.TempDir()
+	cmd := exec.Command(os.Args[0], "-test.run=^TestSignalChild$")
+	cmd.Env = append(os.Environ(), signalChildEnv+"="+mode, signalDirEnv+"="+dir)
+	var errBuf strings.Builder
+	cmd.Stderr = &errBuf
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	line, err := bufio.NewReader(out).ReadString('\n')
+	if err != nil || line != "ready\n" {
+		cmd.Process.Kill()
+		t.Fatalf("child not ready: %q, %v", line, err)
+	}
+	if err := cmd.Process.Signal(sig); err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- cmd.Wait() }()
+	select {
+	case err = <-done:
+	case <-time.After(wait):
+		cmd.Process.Kill()
+		err = errors.New("still running")
+		<-done
+	}
+
+	return dir, errBuf.String(), err
+}
+
+// logContents returns everything the child logged in dir
+func logContents(t *testing.T, dir string) string {
+	t.Helper()
+
+	names, err := filepath.Glob(filepath.Join(dir, "*.log"))
+	if err != nil || len(names) != 1 {
+		t.Fatalf("log files %v (%v), want one", names, err)
+	}
+	return readFile(t, names[0])
+}
+
+// signaled reports whether err is a process ending from sig
+func signaled(err error, sig syscall.Signal) bool {
+	var exit *exec.ExitError
+	if !errors.As(err, &exit) {
+		return false
+	}
+	status, ok := exit.Sys().(syscall.WaitStatus)
+	return ok && status.Signaled() && status.Signal() == sig
+}
+
+func TestFlushOnExitReraises(t *testing.T) {
+	dir, _, err := runSignalChild(t, "exit", syscall.SIGTERM, 5*time.Second)
+	if !signaled(err, syscall.SIGTERM) {
+		t.Errorf("child ended with %v, want SIGTERM", err)
+	}
+	if got := logContents(t, dir); got != "INFO - buffered before the signal\n" {
+		t.Errorf("log holds %q, want the buffered entry", got)
+	}
+}
+
+func TestFlushOnExitUnregistered(t *testing.T) {
+	dir, _, err := runSignalChild(t, "exit-unregistered", syscall.SIGTERM, 5*time.Second)
+	if !signaled(err, syscall.SIGTERM) {
+		t.Errorf("child ended with %v, want SIGTERM's default handling back", err)
+	}
+	if got := logContents(t, dir); got != "" {
+		t.Errorf("log holds %q, want the buffered entry lost without a handler", got)
+	}
+}
+
+func TestFlushOnExitReregisters(t *testing.T) {
+	a := newTestLogger(t, &ILog{})
+	b := newTestLogger(t, &ILog{})
+
+	unregisterA := a.FlushOnExit()
+	unregisterB := b.FlushOnExit()
+	unregisterA()
+	unregisterA()
+	exitMu.Lock()
+	running := exitSignals.ch != nil
+	exitMu.Unlock()
+	if !running {
+		t.Fatal("handler stopped while a logger was still registered")
+	}
+
+	unregisterB()
+	exitMu.Lock()
+	running = exitSignals.ch != nil
+	exitMu.Unlock()
+	if running {
+		t.Fatal("signals still captured after the last logger unregistered")
+	}
+
+	defer a.FlushOnExit()()
+	exitMu.Lock()
+	running = exitSignals.ch != nil
+	exitMu.Unlock()
+	if !running {
+		t.Error("registering again didn't restart the handler")
+	}
+}
+
+func TestFlushOnExitLeavesAppHandler(t *testing.T) {
+	dir, _, err := runSignalChild(t, "exit-app-handler", syscall.SIGTERM, 5*time.Second)
+	if err != nil {
+		t.Errorf("child ended with %v, want the app handler's clean exit", err)
+	}
+	if !exists(filepath.Join(dir, "app-handler")) {
+		t.Error("the application's handler was cut short")
+	}
+	if got := logContents(t, dir); got != "INFO - buffered before the signal\n" {
+		t.Errorf("log holds %q, want the buffered entry", got)
+	}
+}
+
+func TestDumpOnQuitReraises(t *testing.T) {
+	dir, stderr, err := runSignalChild(t, "quit", syscall.SIGQUIT, 5*time.Second)
+	var exit *exec.ExitError
+	if !errors.As(err, &exit) || exit.ExitCode() != 2 || !strings.Contains(stderr, "SIGQUIT") {
+		t.Errorf("child ended with %v, want Go's SIGQUIT dump and exit", err)
+	}
+
+	got := logContents(t, dir)
+	if !strings.HasPrefix(got, "INFO - buffered before the signal\nSIGQUIT goroutine dump stack=") || !strings.Contains(got, "goroutine ") {
+		t.Errorf("log holds %q, want the buffered entry and the stack dump", got)
+	}
+}
+
+func TestDumpOnQuitContinues(t *testing.T) {
+	dir, _, err := runSignalChild(t, "quit-continue", syscall.SIGQUIT, time.Second)
+	if err == nil || err.Error() != "still running" {
+		t.Errorf("child ended with %v, want it to carry on", err)
+	}
+	if got := logContents(t, dir); !strings.Contains(got, "SIGQUIT goroutine dump stack=") {
+		t.Errorf("log holds %q, want the stack dump", got)
+	}
+}
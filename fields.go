@@ -0,0 +1,75 @@
+package ilogger
+
+// Field is a typed key/value pair for LogFields, constructed like zap's fields so
+// existing field-building code carries over
+type Field struct {
+	Key   string
+	Value interface{}
+}
+
+// String returns a string field
+func String(key, value string) Field {
+	return Field{Key: key, Value: value}
+}
+
+// Int returns an integer field
+func Int(key string, value int) Field {
+	return Field{Key: key, Value: value}
+}
+
+// ErrorField returns an "error" field holding err, zap's Error; a nil err is skipped, as
+// in zap. The name Error is the package-level logging func.
+func ErrorField(err error) Field {
+	if err == nil {
+		return Field{}
+	}
+	return Field{Key: "error", Value: err}
+}
+
+// Any returns a field holding any value, rendered by the encoder
+func Any(key string, value interface{}) Field {
+	return Field{Key: key, Value: value}
+}
+
+// LogFields logs msg at level with fields; text output writes them as key=value pairs
+// and JSON output as members of the entry
+func (i *ILog) LogFields(level LogLevel, msg string, fields ...Field) {
+	// skip entry, logOp, logFields and LogFields to reach the caller
+	i.logFields(4, level, msg, fields)
+}
+
+// ErrorFields logs like Errorf with fields
+func (i *ILog) ErrorFields(msg string, fields ...Field) {
+	i.logFields(4, LError, msg, fields)
+}
+
+// WarnFields logs like Warn with fields
+func (i *ILog) WarnFields(msg string, fields ...Field) {
+	i.logFields(4, LWarn, msg, fields)
+}
+
+// InfoFields logs like Info with fields
+func (i *ILog) InfoFields(msg string, fields ...Field) {
+	i.logFields(4, LInfo, msg, fields)
+}
+
+// DebugFields logs like Debug with fields
+func (i *ILog) DebugFields(msg string, fields ...Field) {
+	i.logFields(4, LDebug, msg, fields)
+}
+
+// logFields converts fields to the entry's map, dropping those without a key
+func (i *ILog) logFields(skip int, level LogLevel, msg string, fields []Field) {
+	if !i.enabled(level) {
+		return
+	}
+
+	m := make(map[string]interface{}, len(fields))
+	for _, f := range fields {
+		if f.Key != "" {
+			m[f.Key] = f.Value
+		}
+	}
+
+	i.logOp(skip, level, msg, m)
+}
@@ -0,0 +1,58 @@
+package ilogger
+
+import (
+	"errors"
+	"log"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFieldConstructors(t *testing.T) {
+	err := errors.New("timeout")
+
+	tests := []struct {
+		got, want Field
+	}{
+		{String("user", "ann"), Field{Key: "user", Value: "ann"}},
+		{Int("attempt", 3), Field{Key: "attempt", Value: 3}},
+		{ErrorField(err), Field{Key: "error", Value: err}},
+		{ErrorField(nil), Field{}},
+		{Any("tags", []string{"a", "b"}), Field{Key: "tags", Value: []string{"a", "b"}}},
+	}
+	for _, tt := range tests {
+		if !reflect.DeepEqual(tt.got, tt.want) {
+			t.Errorf("got %+v, want %+v", tt.got, tt.want)
+		}
+	}
+}
+
+func TestFieldsRendering(t *testing.T) {
+	fields := []Field{
+		String("user", "ann lee"),
+		Int("attempt", 3),
+		ErrorField(errors.New("timeout")),
+		ErrorField(nil),
+		Any("ok", false),
+	}
+
+	text := newTestLogger(t, &ILog{Flags: log.Lmsgprefix | log.Lshortfile})
+	text.WarnFields("retrying", fields...)
+	got := readLog(t, text)
+	if !strings.HasPrefix(got, "fields_test.go:") || !strings.HasSuffix(got, `: WARN - retrying attempt=3 error=timeout ok=false user="ann lee"`+"\n") {
+		t.Errorf("text got %q", got)
+	}
+
+	js := newTestLogger(t, &ILog{Encoder: JSONEncoder{}})
+	js.LogFields(LInfo, "retrying", fields...)
+	e := jsonEntries(t, js)[0]
+	want := map[string]interface{}{"user": "ann lee", "attempt": 3.0, "error": "timeout", "ok": false, "msg": "retrying"}
+	for k, v := range want {
+		if e[k] != v {
+			t.Errorf("JSON %s = %v, want %v", k, e[k], v)
+		}
+	}
+	if _, ok := e[""]; ok {
+		t.Error("the nil error field was written")
+	}
+}
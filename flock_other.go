@@ -0,0 +1,14 @@
+//go:build !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd
+// +build !darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd
+
+package ilogger
+
+// lockFile is a no-op where flock isn't available
+func lockFile(f File) error {
+	return nil
+}
+
+// unlockFile is a no-op where flock isn't available
+func unlockFile(f File) error {
+	return nil
+}
@@ -0,0 +1,25 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+// +build darwin dragonfly freebsd linux netbsd openbsd
+
+package ilogger
+
+import "syscall"
+
+// lockFile takes an exclusive advisory lock shared with other processes using the file;
+// files without a descriptor, such as those from a custom FS, are left unlocked
+func lockFile(f File) error {
+	fd, ok := f.(interface{ Fd() uintptr })
+	if !ok {
+		return nil
+	}
+	return syscall.Flock(int(fd.Fd()), syscall.LOCK_EX)
+}
+
+// unlockFile releases the lock taken by lockFile
+func unlockFile(f File) error {
+	fd, ok := f.(interface{ Fd() uintptr })
+	if !ok {
+		return nil
+	}
+	return syscall.Flock(int(fd.Fd()), syscall.LOCK_UN)
+}
@@ -0,0 +1,91 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+// +build darwin dragonfly freebsd linux netbsd openbsd
+
+package ilogger
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// lockWriterEnv makes TestLockLargeWritesAcrossProcesses act as one of its writer processes
+const lockWriterEnv = "ILOGGER_TEST_LOCK_WRITER"
+
+const (
+	lockWriters    = 4
+	lockGoroutines = 4
+	lockLines      = 25
+	lockLineBytes  = 64 << 10
+)
+
+// writeLargeLines logs lines of a single repeated byte per goroutine into dir
+func writeLargeLines(t *testing.T, dir string, id int) {
+	l, err := FromStruct(&ILog{Path: dir, Level: LInfo, Flags: log.Lmsgprefix, LockLargeWrites: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	var wg sync.WaitGroup
+	for g := 0; g < lockGoroutines; g++ {
+		wg.Add(1)
+		go func(c byte) {
+			defer wg.Done()
+			msg := strings.Repeat(string(c), lockLineBytes)
+			for n := 0; n < lockLines; n++ {
+				l.Info("%s", msg)
+			}
+		}(byte('A' + id*lockGoroutines + g))
+	}
+	wg.Wait()
+}
+
+func TestLockLargeWritesAcrossProcesses(t *testing.T) {
+	if dir := os.Getenv(lockWriterEnv); dir != "" {
+		var id int
+		fmt.Sscan(os.Getenv(lockWriterEnv+"_ID"), &id)
+		writeLargeLines(t, dir, id)
+		return
+	}
+
+	dir := t.TempDir()
+	cmds := make([]*exec.Cmd, lockWriters)
+	for n := range cmds {
+		cmds[n] = exec.Command(os.Args[0], "-test.run=^TestLockLargeWritesAcrossProcesses$")
+		cmds[n].Env = append(os.Environ(), lockWriterEnv+"="+dir, fmt.Sprintf("%s_ID=%d", lockWriterEnv, n))
+		cmds[n].Stderr = os.Stderr
+		if err := cmds[n].Start(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, cmd := range cmds {
+		if err := cmd.Wait(); err != nil {
+			t.Fatalf("writer failed: %v", err)
+		}
+	}
+
+	names, err := filepath.Glob(filepath.Join(dir, "*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, name := range names {
+		got = append(got, lines(readFile(t, name))...)
+	}
+
+	if want := lockWriters * lockGoroutines * lockLines; len(got) != want {
+		t.Fatalf("got %d lines, want %d", len(got), want)
+	}
+	for n, line := range got {
+		msg := strings.TrimPrefix(line, "INFO - ")
+		if len(msg) != lockLineBytes || strings.Count(msg, msg[:1]) != lockLineBytes {
+			t.Fatalf("line %d is torn: %d bytes starting %.40q", n, len(line), line)
+		}
+	}
+}
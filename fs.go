@@ -0,0 +1,60 @@
+package ilogger
+
+import (
+	"io"
+	"os"
+)
+
+// FS is the filesystem log files are created in; the OS is used unless ILog.FS is set,
+// e.g. to a fake that injects failures in tests
+type FS interface {
+	MkdirAll(path string, perm os.FileMode) error
+	OpenFile(name string, flag int, perm os.FileMode) (File, error)
+	Stat(name string) (os.FileInfo, error)
+	Rename(oldpath, newpath string) error
+}
+
+// File is an open log file; *os.File implements it
+type File interface {
+	io.Writer
+	io.Closer
+	Name() string
+	Stat() (os.FileInfo, error)
+	Sync() error
+}
+
+// osFS is the FS backed by the os package
+type osFS struct{}
+
+// MkdirAll implements FS
+func (osFS) MkdirAll(path string, perm os.FileMode) error {
+	return os.MkdirAll(path, perm)
+}
+
+// OpenFile implements FS
+func (osFS) OpenFile(name string, flag int, perm os.FileMode) (File, error) {
+	f, err := os.OpenFile(name, flag, perm)
+	if err != nil {
+		// keep the interface nil rather than holding a nil *os.File
+		return nil, err
+	}
+	return f, nil
+}
+
+// Stat implements FS
+func (osFS) Stat(name string) (os.FileInfo, error) {
+	return os.Stat(name)
+}
+
+// Rename implements FS
+func (osFS) Rename(oldpath, newpath string) error {
+	return os.Rename(oldpath, newpath)
+}
+
+// fs returns the configured FS or the OS
+func (i *ILog) fs() FS {
+	if i.FS == nil {
+		return osFS{}
+	}
+	return i.FS
+}
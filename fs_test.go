@@ -0,0 +1,427 @@
+package ilogger
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// countingFS is the OS file system counting the writes made to files it opens
+type countingFS struct {
+	osFS
+	writes int64
+}
+
+// OpenFile implements FS
+func (c *countingFS) OpenFile(name string, flag int, perm os.FileMode) (File, error) {
+	f, err := c.osFS.OpenFile(name, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+	return &countingFile{File: f, writes: &c.writes}, nil
+}
+
+// countingFile is a File adding each Write to a shared counter
+type countingFile struct {
+	File
+	writes *int64
+}
+
+// Write implements File
+func (f *countingFile) Write(b []byte) (int, error) {
+	atomic.AddInt64(f.writes, 1)
+	return f.File.Write(b)
+}
+
+// memFS is an in-memory FS for exercising failure paths; each call to OpenFile or MkdirAll
+// first takes the next error queued for it, succeeding once its queue is empty
+type memFS struct {
+	mu        sync.Mutex
+	files     map[string]*memFile
+	dirs      map[string]bool
+	openErrs  []error
+	mkdirErrs []error
+	opens     int
+	mkdirs    int
+}
+
+func newMemFS() *memFS {
+	return &memFS{files: map[string]*memFile{}, dirs: map[string]bool{}}
+}
+
+// MkdirAll implements FS
+func (m *memFS) MkdirAll(path string, perm os.FileMode) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.mkdirs++
+	if len(m.mkdirErrs) > 0 {
+		err := m.mkdirErrs[0]
+		m.mkdirErrs = m.mkdirErrs[1:]
+		if err != nil {
+			return &os.PathError{Op: "mkdir", Path: path, Err: err}
+		}
+	}
+	m.dirs[path] = true
+	return nil
+}
+
+// OpenFile implements FS
+func (m *memFS) OpenFile(name string, flag int, perm os.FileMode) (File, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.opens++
+	if len(m.openErrs) > 0 {
+		err := m.openErrs[0]
+		m.openErrs = m.openErrs[1:]
+		if err != nil {
+			return nil, &os.PathError{Op: "open", Path: name, Err: err}
+		}
+	}
+	if !m.dirs[filepath.Dir(name)] {
+		return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrNotExist}
+	}
+
+	f, ok := m.files[name]
+	if !ok {
+		f = &memFile{name: name}
+		m.files[name] = f
+	}
+	if flag&os.O_TRUNC != 0 {
+		f.data.Reset()
+	}
+	f.mod = time.Now()
+	return &memHandle{memFile: f, fs: m}, nil
+}
+
+// Stat implements FS
+func (m *memFS) Stat(name string) (os.FileInfo, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	f, ok := m.files[name]
+	if !ok {
+		return nil, &os.PathError{Op: "stat", Path: name, Err: os.ErrNotExist}
+	}
+	return memInfo{name: filepath.Base(name), size: int64(f.data.Len()), mod: f.mod}, nil
+}
+
+// Rename implements FS
+func (m *memFS) Rename(oldpath, newpath string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	f, ok := m.files[oldpath]
+	if !ok {
+		return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: os.ErrNotExist}
+	}
+	delete(m.files, oldpath)
+	f.name = newpath
+	m.files[newpath] = f
+	return nil
+}
+
+// contents returns the data written to name
+func (m *memFS) contents(name string) string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if f, ok := m.files[name]; ok {
+		return f.data.String()
+	}
+	return ""
+}
+
+// failWrites makes the next n writes to name fail with err, or every write when n < 0
+func (m *memFS) failWrites(name string, n int, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.files[name].writeErr = err
+	m.files[name].writeFails = n
+}
+
+// memFile is the contents of a memFS file
+type memFile struct {
+	name     string
+	data     bytes.Buffer
+	mod      time.Time
+	writeErr error
+	// writeFails counts down the writes still to fail with writeErr; negative fails all
+	writeFails int
+}
+
+// memHandle is an open memFile
+type memHandle struct {
+	*memFile
+	fs     *memFS
+	closed bool
+}
+
+// Write implements File
+func (h *memHandle) Write(b []byte) (int, error) {
+	h.fs.mu.Lock()
+	defer h.fs.mu.Unlock()
+
+	if h.closed {
+		return 0, os.ErrClosed
+	}
+	if h.writeErr != nil && h.writeFails != 0 {
+		h.writeFails--
+		return 0, h.writeErr
+	}
+	h.mod = time.Now()
+	return h.data.Write(b)
+}
+
+// Name implements File
+func (h *memHandle) Name() string {
+	h.fs.mu.Lock()
+	defer h.fs.mu.Unlock()
+
+	return h.name
+}
+
+// Stat implements File
+func (h *memHandle) Stat() (os.FileInfo, error) {
+	return h.fs.Stat(h.Name())
+}
+
+// Sync implements File
+func (h *memHandle) Sync() error {
+	return nil
+}
+
+// Close implements File
+func (h *memHandle) Close() error {
+	h.fs.mu.Lock()
+	defer h.fs.mu.Unlock()
+
+	if h.closed {
+		return os.ErrClosed
+	}
+	h.closed = true
+	return nil
+}
+
+// memInfo is the os.FileInfo of a memFile
+type memInfo struct {
+	name string
+	size int64
+	mod  time.Time
+}
+
+func (fi memInfo) Name() string       { return fi.name }
+func (fi memInfo) Size() int64        { return fi.size }
+func (fi memInfo) Mode() os.FileMode  { return 0644 }
+func (fi memInfo) ModTime() time.Time { return fi.mod }
+func (fi memInfo) IsDir() bool        { return false }
+func (fi memInfo) Sys() interface{}   { return nil }
+
+func TestNewFileFSErrors(t *testing.T) {
+	t.Run("mkdir denied", func(t *testing.T) {
+		fs := newMemFS()
+		fs.mkdirErrs = []error{os.ErrPermission}
+		l := &ILog{Path: "/logs", FS: fs}
+
+		err := l.NewFile(l.Path, 0, int(LInfo))
+		if !errors.Is(err, os.ErrPermission) || !strings.Contains(err.Error(), "cannot make log path") {
+			t.Errorf("NewFile = %v, want the mkdir error", err)
+		}
+		if fs.opens != 0 || l.logOpen {
+			t.Error("NewFile opened a file after the directory failed")
+		}
+	})
+
+	t.Run("open denied then recovered", func(t *testing.T) {
+		var warnings strings.Builder
+		log.SetOutput(&warnings)
+		defer log.SetOutput(os.Stderr)
+
+		fs := newMemFS()
+		fs.openErrs = []error{os.ErrPermission, os.ErrPermission}
+		l := &ILog{Path: "/logs", FS: fs, Flags: log.Lmsgprefix}
+		if err := l.NewFile(l.Path, 0, int(LInfo)); !errors.Is(err, os.ErrPermission) {
+			t.Fatalf("NewFile = %v, want the open error", err)
+		}
+		defer l.Close()
+
+		// each write tries to open the file again, dropping the entry when it can't
+		l.Info("dropped")
+		l.Info("written")
+		if got := fs.contents(l.fileName(time.Now(), 0)); got != "INFO - written\n" {
+			t.Errorf("file holds %q", got)
+		}
+		if !strings.Contains(warnings.String(), "dropping 1 entries") {
+			t.Errorf("drop not reported: %q", warnings.String())
+		}
+	})
+
+	t.Run("size rotation", func(t *testing.T) {
+		fs := newMemFS()
+		l := &ILog{Path: "/logs", FS: fs, Flags: log.Lmsgprefix, MaxBytes: 15}
+		if err := l.NewFile(l.Path, 0, int(LInfo)); err != nil {
+			t.Fatal(err)
+		}
+		defer l.Close()
+
+		l.Info("first entry")
+		l.Info("second entry")
+		l.Info("third")
+
+		now := time.Now()
+		if got := fs.contents(l.fileName(now, 0)); got != "INFO - first entry\n" {
+			t.Errorf("first file holds %q", got)
+		}
+		if got := fs.contents(l.fileName(now, 1)); got != "INFO - second entry\n" {
+			t.Errorf("second file holds %q", got)
+		}
+		if got := fs.contents(l.fileName(now, 2)); got != "INFO - third\n" {
+			t.Errorf("third file holds %q", got)
+		}
+	})
+}
+
+func TestOnWriteError(t *testing.T) {
+	errFull := errors.New("no space left on device")
+
+	// open returns a logger on a fresh memFS, capturing the log package's reports
+	open := func(t *testing.T, policy WriteErrorPolicy) (*ILog, *memFS, string, *strings.Builder) {
+		var warnings strings.Builder
+		log.SetOutput(&warnings)
+		t.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+		fs := newMemFS()
+		l := &ILog{Path: "/logs", FS: fs, Flags: log.Lmsgprefix, OnWriteError: policy, OpenRetries: 3, OpenBackoff: time.Millisecond}
+		if err := l.NewFile(l.Path, 0, int(LInfo)); err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { l.Close() })
+
+		return l, fs, l.fileName(time.Now(), 0), &warnings
+	}
+
+	t.Run("drop", func(t *testing.T) {
+		l, fs, name, warnings := open(t, WriteErrorDrop)
+		fs.failWrites(name, 1, errFull)
+		l.Info("lost")
+		l.Info("kept")
+
+		if got := fs.contents(name); got != "INFO - kept\n" {
+			t.Errorf("file holds %q", got)
+		}
+		if !strings.Contains(warnings.String(), "no space left") {
+			t.Errorf("failure not reported: %q", warnings.String())
+		}
+	})
+
+	t.Run("stderr", func(t *testing.T) {
+		r, w, err := os.Pipe()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer r.Close()
+		stderr := os.Stderr
+		os.Stderr = w
+		defer func() { os.Stderr = stderr }()
+
+		l, fs, name, warnings := open(t, WriteErrorStderr)
+		fs.failWrites(name, 1, errFull)
+		l.Info("diverted")
+		l.Info("kept")
+		w.Close()
+
+		b, _ := ioutil.ReadAll(r)
+		if got := string(b); got != "INFO - diverted\n" {
+			t.Errorf("stderr got %q", got)
+		}
+		if got := fs.contents(name); got != "INFO - kept\n" {
+			t.Errorf("file holds %q", got)
+		}
+		if !strings.Contains(warnings.String(), "no space left") {
+			t.Errorf("failure not reported: %q", warnings.String())
+		}
+	})
+
+	t.Run("retry", func(t *testing.T) {
+		l, fs, name, warnings := open(t, WriteErrorRetry)
+		// the first write and two retries fail, the last retry gets through
+		fs.failWrites(name, 3, errFull)
+		l.Info("retried")
+
+		if got := fs.contents(name); got != "INFO - retried\n" {
+			t.Errorf("file holds %q", got)
+		}
+		if warnings.Len() != 0 {
+			t.Errorf("recovered write reported: %q", warnings.String())
+		}
+	})
+
+	t.Run("retry exhausted", func(t *testing.T) {
+		l, fs, name, warnings := open(t, WriteErrorRetry)
+		fs.failWrites(name, -1, errFull)
+		l.Info("lost")
+		fs.failWrites(name, 0, nil)
+		l.Info("kept")
+
+		if got := fs.contents(name); got != "INFO - kept\n" {
+			t.Errorf("file holds %q", got)
+		}
+		if !strings.Contains(warnings.String(), "no space left") {
+			t.Errorf("failure not reported: %q", warnings.String())
+		}
+	})
+}
+
+func TestWriteBufferWriteError(t *testing.T) {
+	var warnings strings.Builder
+	log.SetOutput(&warnings)
+	defer log.SetOutput(os.Stderr)
+
+	for _, policy := range []WriteErrorPolicy{WriteErrorDrop, WriteErrorRetry} {
+		fs := newMemFS()
+		l := &ILog{Path: "/logs", FS: fs, Flags: log.Lmsgprefix, WriteBufferBytes: 1 << 10, OnWriteError: policy, OpenBackoff: time.Millisecond}
+		if err := l.NewFile(l.Path, 0, int(LInfo)); err != nil {
+			t.Fatal(err)
+		}
+		name := l.fileName(time.Now(), 0)
+
+		l.Info("first")
+		fs.failWrites(name, 1, errors.New("transient"))
+		err := l.Sync()
+		if policy == WriteErrorDrop && err == nil {
+			t.Errorf("%v: Sync hid the failed flush", policy)
+		}
+		if policy == WriteErrorRetry && err != nil {
+			t.Errorf("%v: Sync = %v after the retry got through", policy, err)
+		}
+
+		// the buffer must not stay stuck on the error
+		l.Info("second")
+		if err := l.Sync(); err != nil {
+			t.Errorf("%v: Sync = %v after the failure passed", policy, err)
+		}
+		l.Info("third")
+		l.Close()
+
+		want := "INFO - second\nINFO - third\n"
+		if policy == WriteErrorRetry {
+			want = "INFO - first\n" + want
+		}
+		if got := fs.contents(name); got != want {
+			t.Errorf("%v: file holds %q, want %q", policy, got, want)
+		}
+	}
+	if !strings.Contains(warnings.String(), "transient") {
+		t.Errorf("failure not reported: %q", warnings.String())
+	}
+}
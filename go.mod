@@ -3,7 +3,9 @@ module github.com/jbsturgeon/ilogger
 go 1.16
 
 require (
+	github.com/fsnotify/fsnotify v1.4.9
 	github.com/kr/text v0.2.0 // indirect
+	golang.org/x/sys v0.0.0-20210630005230-0f9fa26af87c
 	gopkg.in/check.v1 v1.0.0-20201130134442-10cb98267c6c // indirect
 	gopkg.in/yaml.v2 v2.4.0
 )
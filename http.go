@@ -0,0 +1,183 @@
+package ilogger
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const redacted = "[REDACTED]"
+
+// sensitiveHeaders are never written by LogRequest
+var sensitiveHeaders = map[string]bool{
+	"Authorization":       true,
+	"Proxy-Authorization": true,
+	"Cookie":              true,
+	"Set-Cookie":          true,
+}
+
+// LogRequest logs r at level with "method", "path", "query", "remote" and "header.<Name>"
+// fields. Only the headers named in RequestHeaders are included, or all of them when it is
+// empty; credential headers are always redacted.
+func (i *ILog) LogRequest(level LogLevel, r *http.Request) {
+	if !i.enabled(level) {
+		return
+	}
+
+	fields := map[string]interface{}{
+		"method": r.Method,
+		"path":   r.URL.Path,
+		"remote": r.RemoteAddr,
+	}
+	if r.URL.RawQuery != "" {
+		fields["query"] = r.URL.RawQuery
+	}
+
+	names := i.RequestHeaders
+	if len(names) == 0 {
+		for name := range r.Header {
+			names = append(names, name)
+		}
+	}
+
+	for _, name := range names {
+		name = http.CanonicalHeaderKey(name)
+		values, ok := r.Header[name]
+		if !ok {
+			continue
+		}
+
+		value := strings.Join(values, ", ")
+		if sensitiveHeaders[name] {
+			value = redacted
+		}
+		fields["header."+name] = value
+	}
+
+	// skip entry, logOp and LogRequest to reach the caller
+	i.logOp(3, level, "request", fields)
+}
+
+// LogResponse logs the status and size of a response at level as "status" and "size" fields
+func (i *ILog) LogResponse(level LogLevel, status int, size int64) {
+	if !i.enabled(level) {
+		return
+	}
+
+	// skip entry, logOp and LogResponse to reach the caller
+	i.logOp(3, level, "response", map[string]interface{}{"status": status, "size": size})
+}
+
+// LogCombined logs the request as an Apache/Nginx combined log format line, without a
+// level prefix, for analyzers that expect that layout after the line header
+func (i *ILog) LogCombined(level LogLevel, r *http.Request, status int, size int64) {
+	i.log(level, false, "%s", CombinedLogLine(r, time.Now(), status, size))
+}
+
+// CombinedLogLine renders a request in the combined log format:
+// remote ident user [time] "request" status size "referer" "user-agent"
+func CombinedLogLine(r *http.Request, t time.Time, status int, size int64) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+
+	user := "-"
+	if r.URL.User != nil && r.URL.User.Username() != "" {
+		user = r.URL.User.Username()
+	} else if name, _, ok := r.BasicAuth(); ok && name != "" {
+		user = name
+	}
+
+	uri := r.RequestURI
+	if uri == "" {
+		uri = r.URL.RequestURI()
+	}
+
+	sent := "-"
+	if size > 0 {
+		sent = strconv.FormatInt(size, 10)
+	}
+
+	return fmt.Sprintf("%s - %s [%s] %s %d %s %s %s",
+		dash(host), combinedEscape(user), t.Format("02/Jan/2006:15:04:05 -0700"),
+		combinedQuote(r.Method+" "+uri+" "+r.Proto), status, sent,
+		combinedQuote(r.Referer()), combinedQuote(r.UserAgent()))
+}
+
+// dash returns s, or "-" for an empty value as the combined format does
+func dash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// combinedQuote double quotes s for the combined format, writing "-" for an empty value
+func combinedQuote(s string) string {
+	return `"` + combinedEscape(dash(s)) + `"`
+}
+
+// combinedEscape backslash escapes quotes, backslashes and control characters the way Apache does
+func combinedEscape(s string) string {
+	var b strings.Builder
+	for _, c := range []byte(s) {
+		switch {
+		case c == '"' || c == '\\':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case c < 0x20 || c == 0x7f:
+			fmt.Fprintf(&b, `\x%02x`, c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// TraceFields returns "trace_id" and "span_id" fields from the W3C traceparent header
+// of r, for LogFields, so entries correlate with traces; nil when the header is missing
+// or malformed
+func TraceFields(r *http.Request) []Field {
+	// version "-" trace-id "-" parent-id "-" trace-flags, all lowercase hex
+	parts := strings.Split(strings.TrimSpace(r.Header.Get("traceparent")), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" || (parts[0] == "00" && len(parts) != 4) {
+		return nil
+	}
+
+	traceID, spanID := parts[1], parts[2]
+	if !lowerHex(traceID, 32) || !lowerHex(spanID, 16) || !lowerHex(parts[0], 2) || !lowerHex(parts[3], 2) {
+		return nil
+	}
+	// all-zero IDs are invalid
+	if strings.Trim(traceID, "0") == "" || strings.Trim(spanID, "0") == "" {
+		return nil
+	}
+
+	return []Field{String("trace_id", traceID), String("span_id", spanID)}
+}
+
+// lowerHex reports whether s is n lowercase hex digits
+func lowerHex(s string, n int) bool {
+	if len(s) != n {
+		return false
+	}
+	for _, c := range s {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// field renders a key=value pair, quoting values that would otherwise be ambiguous
+func field(key, value string) string {
+	if value == "" || strings.ContainsAny(value, " \t\"=") {
+		value = strconv.Quote(value)
+	}
+
+	return fmt.Sprintf("%s=%s", key, value)
+}
@@ -0,0 +1,173 @@
+package ilogger
+
+import (
+	"encoding/json"
+	"log"
+	"net/http/httptest"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+// jsonEntries decodes each line of a JSON logger's file
+func jsonEntries(tb testing.TB, l *ILog) []map[string]interface{} {
+	tb.Helper()
+
+	var entries []map[string]interface{}
+	for _, line := range lines(readLog(tb, l)) {
+		var e map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &e); err != nil {
+			tb.Fatalf("line %q: %v", line, err)
+		}
+		entries = append(entries, e)
+	}
+	return entries
+}
+
+func TestLogRequestFields(t *testing.T) {
+	l := newTestLogger(t, &ILog{Encoder: JSONEncoder{}})
+
+	r := httptest.NewRequest("POST", "/orders?limit=5", nil)
+	r.RemoteAddr = "10.0.0.7:5123"
+	r.Header.Set("Authorization", "Bearer secret")
+	r.Header.Set("Cookie", "session=abc")
+	r.Header.Set("X-Request-Id", "req-1")
+	l.LogRequest(LInfo, r)
+	l.LogResponse(LInfo, 201, 512)
+
+	entries := jsonEntries(t, l)
+	if len(entries) != 2 {
+		t.Fatalf("got %d entries, want 2", len(entries))
+	}
+
+	req := entries[0]
+	want := map[string]interface{}{
+		"msg":                  "request",
+		"method":               "POST",
+		"path":                 "/orders",
+		"query":                "limit=5",
+		"remote":               "10.0.0.7:5123",
+		"header.Authorization": redacted,
+		"header.Cookie":        redacted,
+		"header.X-Request-Id":  "req-1",
+	}
+	for k, v := range want {
+		if req[k] != v {
+			t.Errorf("request %s = %v, want %v", k, req[k], v)
+		}
+	}
+
+	resp := entries[1]
+	if resp["msg"] != "response" || resp["status"] != 201.0 || resp["size"] != 512.0 {
+		t.Errorf("response entry = %v", resp)
+	}
+}
+
+func TestLogRequestHeaderSelection(t *testing.T) {
+	l := newTestLogger(t, &ILog{Encoder: JSONEncoder{}, RequestHeaders: []string{"x-request-id", "authorization"}})
+
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("Authorization", "Basic c2VjcmV0")
+	r.Header.Set("X-Request-Id", "req-2")
+	r.Header.Set("User-Agent", "curl")
+	l.LogRequest(LInfo, r)
+
+	e := jsonEntries(t, l)[0]
+	if e["header.X-Request-Id"] != "req-2" || e["header.Authorization"] != redacted {
+		t.Errorf("selected headers = %v", e)
+	}
+	if _, ok := e["header.User-Agent"]; ok {
+		t.Error("unselected header logged")
+	}
+	if _, ok := e["query"]; ok {
+		t.Error("empty query logged")
+	}
+}
+
+func TestLogRequestFilteredDoesNotAllocate(t *testing.T) {
+	l := newTestLogger(t, &ILog{Level: LError})
+	r := httptest.NewRequest("GET", "/health", nil)
+	r.Header.Set("X-Request-Id", "req-3")
+
+	allocs := testing.AllocsPerRun(100, func() {
+		l.LogRequest(LInfo, r)
+		l.LogResponse(LInfo, 200, 2)
+	})
+	if allocs != 0 {
+		t.Errorf("filtered access logging allocated %v times, want 0", allocs)
+	}
+}
+
+func TestCombinedLogLine(t *testing.T) {
+	at := time.Date(2023, 5, 10, 14, 3, 7, 0, time.FixedZone("", -7*3600))
+
+	r := httptest.NewRequest("GET", "/apache_pb.gif?x=1", nil)
+	r.RemoteAddr = "127.0.0.1:51234"
+	r.SetBasicAuth("frank", "secret")
+	r.Header.Set("Referer", "http://www.example.com/start.html")
+	r.Header.Set("User-Agent", `Mozilla/4.08 "quoted"`)
+
+	want := `127.0.0.1 - frank [10/May/2023:14:03:07 -0700] "GET /apache_pb.gif?x=1 HTTP/1.1" 200 2326 "http://www.example.com/start.html" "Mozilla/4.08 \"quoted\""`
+	if got := CombinedLogLine(r, at, 200, 2326); got != want {
+		t.Errorf("got  %s\nwant %s", got, want)
+	}
+
+	bare := httptest.NewRequest("HEAD", "/", nil)
+	bare.RemoteAddr = "[::1]:80"
+	want = `::1 - - [10/May/2023:14:03:07 -0700] "HEAD / HTTP/1.1" 304 - "-" "-"`
+	if got := CombinedLogLine(bare, at, 304, 0); got != want {
+		t.Errorf("got  %s\nwant %s", got, want)
+	}
+}
+
+func TestLogCombined(t *testing.T) {
+	l := newTestLogger(t, &ILog{Flags: log.Lmsgprefix})
+
+	r := httptest.NewRequest("POST", "/orders", nil)
+	r.RemoteAddr = "10.0.0.7:5123"
+	l.LogCombined(LInfo, r, 201, 12)
+
+	got := readLog(t, l)
+	re := regexp.MustCompile(`^10\.0\.0\.7 - - \[\d\d/\w{3}/\d{4}:\d\d:\d\d:\d\d [-+]\d{4}\] "POST /orders HTTP/1\.1" 201 12 "-" "-"\n$`)
+	if !re.MatchString(got) {
+		t.Errorf("unexpected line %q", got)
+	}
+}
+
+func TestTraceFields(t *testing.T) {
+	const traceID, spanID = "4bf92f3577b34da6a3ce929d0e0e4736", "00f067aa0ba902b7"
+
+	tests := []struct {
+		header string
+		want   []Field
+	}{
+		{"00-" + traceID + "-" + spanID + "-01", []Field{String("trace_id", traceID), String("span_id", spanID)}},
+		{" 01-" + traceID + "-" + spanID + "-00-future ", []Field{String("trace_id", traceID), String("span_id", spanID)}},
+		{"", nil},
+		{"00-" + traceID + "-" + spanID + "-01-extra", nil},
+		{"ff-" + traceID + "-" + spanID + "-01", nil},
+		{"00-" + strings.ToUpper(traceID) + "-" + spanID + "-01", nil},
+		{"00-00000000000000000000000000000000-" + spanID + "-01", nil},
+		{"00-" + traceID + "-0000000000000000-01", nil},
+		{"00-" + traceID[:30] + "-" + spanID + "-01", nil},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", "/", nil)
+		if tt.header != "" {
+			r.Header.Set("traceparent", tt.header)
+		}
+		if got := TraceFields(r); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%q: got %v, want %v", tt.header, got, tt.want)
+		}
+	}
+
+	l := newTestLogger(t, &ILog{Encoder: JSONEncoder{}})
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("traceparent", "00-"+traceID+"-"+spanID+"-01")
+	l.InfoFields("handled", TraceFields(r)...)
+	if e := jsonEntries(t, l)[0]; e["trace_id"] != traceID || e["span_id"] != spanID {
+		t.Errorf("entry lacks the trace fields: %v", e)
+	}
+}
@@ -1,12 +1,26 @@
 package ilogger
 
 import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"log"
 	"os"
 	"path/filepath"
+	"regexp"
+	"runtime"
+	"runtime/debug"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	yaml "gopkg.in/yaml.v2"
@@ -22,18 +36,80 @@ const (
 	magentaEnum
 )
 
+// ANSI escape codes for the color enums
+var colorCodes = map[int]string{
+	whiteEnum:   "\x1b[37m",
+	cyanEnum:    "\x1b[36m",
+	blueEnum:    "\x1b[34m",
+	greenEnum:   "\x1b[32m",
+	yellowEnum:  "\x1b[33m",
+	redEnum:     "\x1b[31m",
+	magentaEnum: "\x1b[35m",
+}
+
+const (
+	colorReset = "\x1b[0m"
+
+	// DefaultFlags are the log.Logger flags used when Flags are unset; LUTC keeps
+	// line timestamps on the same UTC basis as file names and rotation
+	DefaultFlags = log.LstdFlags | log.Lmicroseconds | log.LUTC
+)
+
 var (
-	colorMap = map[LogLevel]int{}
+	colorMap  = map[LogLevel]int{}
+	colorList []LogColor
+
+	// custom levels added with RegisterLevel
+	levelMu      sync.RWMutex
+	customLevels = map[string]LogLevel{}
+
+	// levelPrefixes is the message prefix for each level, standard and registered
+	levelPrefixes = defaultPrefixes()
+	// levelNames is the name of each level, standard and registered
+	levelNames = defaultNames()
 )
 
+// defaultNames returns the name table for the standard levels
+func defaultNames() map[LogLevel]string {
+	return map[LogLevel]string{
+		LMandatory: "MANDATORY",
+		LError:     "ERROR",
+		LWarn:      "WARN",
+		LInfo:      "INFO",
+		LDebug:     "DEBUG",
+	}
+}
+
+// defaultPrefixes returns the prefix table for the standard levels
+func defaultPrefixes() map[LogLevel]string {
+	return map[LogLevel]string{
+		LError: errorPrefix,
+		LWarn:  warnPrefix,
+		LInfo:  infoPrefix,
+		LDebug: debugPrefix,
+	}
+}
+
 const (
 	logLevelEnv    = "LOG_LEVEL"
 	colorConfigEnv = "LOG_COLOR_CONFIG"
+	disableEnv     = "LOG_DISABLE"
+	outputEnv      = "LOG_OUTPUT"
+	fileEnv        = "LOG_FILE"
+	versionEnv     = "LOG_VERSION"
+	// labelEnvPrefix starts env vars whose values become labels, e.g. LOG_LABEL_POD=web-1
+	labelEnvPrefix = "LOG_LABEL_"
 
 	debugPrefix = "DEBUG - "
 	infoPrefix  = "INFO - "
 	warnPrefix  = "WARN - "
 	errorPrefix = "ERROR - "
+
+	// pipeBuf is the POSIX minimum for PIPE_BUF, the largest write guaranteed not to interleave
+	pipeBuf = 512
+
+	// headerVersion is bumped whenever the file header or line layout changes
+	headerVersion = 1
 )
 
 // LogColor type used to specify log level and color
@@ -43,8 +119,20 @@ type LogColor struct {
 }
 
 var (
-	logLevelConfig = os.Getenv(logLevelEnv)
+	logLevelConfig string
+	logVersion     string
 	showColors     bool
+	logDisabled    bool
+
+	// outputStream, outputDir and outputFile are the destination from LOG_OUTPUT or
+	// LOG_FILE; a stream replaces the log files entirely, a directory replaces every
+	// logger's Path, and a file is written as named, without dated names or rotation
+	outputStream *os.File
+	outputDir    string
+	outputFile   string
+
+	// envLabels are the labels from LOG_LABEL_* variables, keyed by the lowercased suffix
+	envLabels map[string]string
 )
 
 // Logging levels
@@ -59,18 +147,291 @@ const (
 // LogLevel is a logging level
 type LogLevel uint8
 
+// String returns the level's name, including names added with RegisterLevel
+func (l LogLevel) String() string {
+	levelMu.RLock()
+	defer levelMu.RUnlock()
+
+	if name, ok := levelNames[l]; ok {
+		return name
+	}
+	return fmt.Sprintf("LEVEL(%d)", uint8(l))
+}
+
+// Rotation intervals
+const (
+	RotateDaily = RotationInterval(iota)
+	RotateWeekly
+	RotateMonthly
+)
+
+// RotationInterval is how often a new log file is started
+type RotationInterval uint8
+
+// String returns "daily", "weekly" or "monthly"
+func (r RotationInterval) String() string {
+	switch r {
+	case RotateDaily:
+		return "daily"
+	case RotateWeekly:
+		return "weekly"
+	case RotateMonthly:
+		return "monthly"
+	default:
+		return fmt.Sprintf("RotationInterval(%d)", uint8(r))
+	}
+}
+
+// key identifies the rotation period containing t; a new file is opened when it changes
+func (r RotationInterval) key(t time.Time) int {
+	switch r {
+	case RotateWeekly:
+		year, week := t.ISOWeek()
+		return year*100 + week
+	case RotateMonthly:
+		return t.Year()*100 + int(t.Month())
+	default:
+		return t.Year()*1000 + t.YearDay()
+	}
+}
+
+// suffix is the date portion of the log file name for the period containing t
+func (r RotationInterval) suffix(t time.Time) string {
+	switch r {
+	case RotateWeekly:
+		year, week := t.ISOWeek()
+		return fmt.Sprintf("%04d_W%02d", year, week)
+	case RotateMonthly:
+		return t.Format("2006_01")
+	default:
+		return t.Format("2006_01_02")
+	}
+}
+
 // ILog struct for logging variables
 type ILog struct {
-	Path  string
+	// atomic byte counters, kept first so they are 64-bit aligned on 32-bit platforms
+	fileBytes uint64
+	dayBytes  uint64
+	day       int64
+
+	Path string
+	// Level is the most verbose level written; once logging has started, change it with
+	// SetLogLevel so goroutines that are logging see the change safely
 	Level LogLevel
 
-	fileDay int
-	logFile *os.File
-	logOpen bool
-	iLog    *log.Logger
+	// ErrorType appends the type of a trailing error param to its rendered chain
+	ErrorType bool
+	// Rotation sets how often a new file is started; defaults to daily
+	Rotation RotationInterval
+	// Component is prepended to every message to tag the subsystem logging it
+	Component string
+	// Version is added to every entry as a "version" field, e.g. the build's release or
+	// commit; when empty, NewFile sets it from LOG_VERSION
+	Version string
+	// Tags are added to every entry, ahead of any tags of its own
+	Tags []string
+	// OnRotate is called with the file name each time a new log file is opened, after the
+	// logger's lock is released, so it may log itself
+	OnRotate func(name string)
+	// LockLargeWrites holds an flock on the file while writing entries larger than PIPE_BUF,
+	// so processes sharing the file can't tear each other's lines
+	LockLargeWrites bool
+	// OnLog is called for every entry that passes the level filter, e.g. to count
+	// messages per level; it runs after the logger's locks are released, so it may log
+	// itself, and a panic inside it is recovered and reported
+	OnLog func(level LogLevel, msg string)
+	// MaxBytes also rotates within a period once the file reaches this size; 0 disables it
+	MaxBytes int64
+	// MaxFiles caps the MaxBytes files in a period; once all are full the oldest is
+	// truncated and reused, ring fashion, instead of starting another. 0 disables it.
+	MaxFiles int
+	// LengthPrefix frames each entry, and the header, with its length as 4 bytes big-endian
+	// in place of the trailing newline, for stream transports that need binary-safe framing
+	LengthPrefix bool
+	// Header writes a JSON metadata line at the top of each newly created file
+	Header bool
+	// CurrentSymlink names a symlink in Path that always points at the active file
+	CurrentSymlink string
+	// Sequence prefixes each emitted message with "[n] ", counting up across rotations
+	Sequence bool
+	// RedactQueryArgs writes every LogQuery argument as "[REDACTED]", keeping only their count
+	RedactQueryArgs bool
+	// RequestHeaders limits the headers written by LogRequest; empty writes them all
+	RequestHeaders []string
+	// Flags are the log.Logger flags for each line; 0 uses DefaultFlags
+	Flags int
+	// Prefix is the log.Logger-style line prefix for text output
+	Prefix string
+	// AllowControls leaves newlines and control characters in text output unescaped
+	AllowControls bool
+	// ColorMode selects whether the whole text line or just the level prefix is colored
+	ColorMode ColorMode
+	// KeywordColors colors these words in messages (e.g. "FAILED": "RED") on top of the
+	// level color, only while colors are enabled and the file is a terminal
+	KeywordColors map[string]string
+	// CompactLevel replaces verbose text prefixes like "ERROR - " with markers like "E "
+	CompactLevel bool
+	// FieldOrder puts these field keys first in text output, ahead of the sorted rest
+	FieldOrder []string
+	// Color decides whether configured colors are written at all; defaults to ColorAuto
+	Color ColorSetting
+	// Encoder renders each entry; nil uses a TextEncoder with the text options above
+	Encoder Encoder
+	// NoCaller skips caller resolution for every encoder, saving a runtime.Caller per entry
+	NoCaller bool
+	// WriteBufferBytes buffers file writes up to this many bytes, flushed on Sync, Close,
+	// rotation and every bufferFlushInterval; entries still buffered are lost if the
+	// process dies, and MaxBytes can be overshot by up to the buffer size. 0 disables it.
+	WriteBufferBytes int
+	// LazyOpen defers creating the directory and file from NewFile until the first entry
+	// is written, so idle loggers leave nothing on disk
+	LazyOpen bool
+	// FS is where log files are created, stat'ed and renamed; nil uses the OS. The
+	// CurrentSymlink link is always made through the os package.
+	FS FS
+	// Ring takes every line in place of files, storing it in the memory-mapped ring
+	// without a write syscall; Path, rotation and LOG_OUTPUT then don't apply. The caller
+	// owns the ring and closes it after the logger, whose Sync leaves it to the ring's own.
+	Ring *MmapRing
+	// OpenRetries retries a failed directory or file open this many times before the
+	// error is returned; writes that can't open a file are dropped and reported
+	OpenRetries int
+	// OpenBackoff is the wait before the first retry, doubling each time; defaults to 50ms
+	OpenBackoff time.Duration
+	// OnWriteError is what happens to a line the file fails to take; defaults to
+	// WriteErrorDrop. With WriteBufferBytes set it applies to the part of a flush the
+	// file did not take, and buffering carries on with the next line.
+	OnWriteError WriteErrorPolicy
+	// FatalCode is the exit status Fatalf uses; 0 means 1
+	FatalCode int
+	// ExitFunc ends the process for Fatalf and Fatalfc; nil uses os.Exit. If it returns,
+	// as a test's recorder would, so do they.
+	ExitFunc func(code int)
+	// NoReraise leaves SIGINT and SIGTERM after FlushOnExit, and SIGQUIT after DumpOnQuit,
+	// to the application's own handling instead of raising them again; set it before those
+	NoReraise bool
+	// MirrorStdlib also forwards every entry to log.Default(), as a bridge for tooling
+	// that still reads the standard library's output during a migration
+	MirrorStdlib bool
+	// BytesFormat renders []byte params and field values as hex or base64 instead of
+	// fmt's decimal list; defaults to BytesRaw, leaving them to fmt and the encoder
+	BytesFormat BytesFormat
+	// Escalation raises the level of messages that repeat too often within its window
+	Escalation Escalation
+	// Sampler, when set, drops the entries its ShouldLog rejects, ahead of RateLimits,
+	// DedupWindow and SampleWindow
+	Sampler Sampler
+	// RateLimits caps each listed level at this many entries per second, allowing bursts
+	// of a second's worth and dropping the excess; see Throttled
+	RateLimits map[LogLevel]int
+	// SampleWindow writes only the first and the last entry of each level in every window
+	// of this length, the last with a "skipped" count of those between; 0 disables it
+	SampleWindow time.Duration
+	// DedupWindow writes only the first of identical messages at the same level within
+	// this window, counting the rest; 0 disables it
+	DedupWindow time.Duration
+	// SkipEmpty drops entries whose formatted message is empty or only whitespace
+	SkipEmpty bool
+	// GoroutineID adds the logging goroutine's ID as a "goroutine" field; it parses
+	// runtime.Stack on every call, so it is meant for debugging only
+	GoroutineID bool
+
+	mu       sync.RWMutex
+	filterMu sync.RWMutex
+	mask     LogLevel
+	fileKey  int
+	fileSeq  int
+	seqMu    sync.Mutex
+	seq      uint64
+	lockMu   sync.Mutex
+	once     sync.Map
+	outMu    sync.Mutex
+	outputs  []*scopedOutput
+	watchers []io.Closer
+	redact   []*regexp.Regexp
+	labels   map[string]string
+	logFile  File
+	logOpen  bool
+	fileTTY  bool
+	// stream is set while logFile is the Ring, or stdout or stderr from LOG_OUTPUT or
+	// fallback, which are never closed, renamed or rotated
+	stream bool
+	// fallback is written to when no Path is set, as for the package default logger
+	fallback *os.File
+	// clock replaces time.Now for rotation decisions and the daily byte count in tests
+	clock func() time.Time
+
+	// buf buffers the current file when WriteBufferBytes is set; bufMu guards it
+	// because writers only share a read lock on mu
+	bufMu     sync.Mutex
+	buf       *bufio.Writer
+	bufOut    *bufferTarget
+	flushStop chan struct{}
+
+	dedup     dedup
+	escalator escalator
+	throttler throttle
+	sampled   windowSampler
+}
+
+// configOnce guards the one-time read of the LOG_* environment
+var configOnce sync.Once
+
+// Init reads LOG_LEVEL, LOG_DISABLE, LOG_OUTPUT, LOG_FILE, LOG_VERSION, LOG_LABEL_* and
+// LOG_COLOR_CONFIG into the package defaults. Importing the package reads nothing; Init runs
+// on its own the first time a logger is opened or writes, so calling it is only needed to
+// load the config earlier.
+func Init() {
+	configOnce.Do(loadConfig)
+}
+
+// ResetForTest clears all package state, including registered levels, and re-reads the
+// environment so tests with different LOG_* settings don't leak into each other.
+// It must not be called while any logger is in use.
+func ResetForTest() {
+	levelMu.Lock()
+	colorMap = map[LogLevel]int{}
+	colorList = nil
+	showColors = false
+	outputStream = nil
+	outputDir = ""
+	outputFile = ""
+	envLabels = nil
+	customLevels = map[string]LogLevel{}
+	levelPrefixes = defaultPrefixes()
+	levelNames = defaultNames()
+	levelMu.Unlock()
+
+	SetDefault(nil)
+
+	configOnce = sync.Once{}
+	Init()
 }
 
-func init() {
+// loadConfig reads the LOG_* environment into the package state
+func loadConfig() {
+	logLevelConfig = os.Getenv(logLevelEnv)
+	logVersion = os.Getenv(versionEnv)
+
+	// turn off all logging when requested
+	logDisabled, _ = strconv.ParseBool(os.Getenv(disableEnv))
+
+	// pick the default destination, LOG_OUTPUT taking precedence over LOG_FILE
+	loadOutput(os.Getenv(outputEnv), os.Getenv(fileEnv))
+
+	for _, kv := range os.Environ() {
+		if !strings.HasPrefix(kv, labelEnvPrefix) {
+			continue
+		}
+		if n := strings.IndexByte(kv, '='); n > len(labelEnvPrefix) {
+			if envLabels == nil {
+				envLabels = map[string]string{}
+			}
+			envLabels[strings.ToLower(kv[len(labelEnvPrefix):n])] = kv[n+1:]
+		}
+	}
+
 	// setup colorMap
 	colorConfig := os.Getenv(colorConfigEnv)
 	if colorConfig != "" {
@@ -78,9 +439,11 @@ func init() {
 		if err != nil {
 			fmt.Printf("Unable to get colors from color config file, Error: %+v\n", err)
 		} else {
-			var colorList []LogColor
 			if err = yaml.Unmarshal(colors, &colorList); err != nil {
 				fmt.Printf("Unable to unmarshal colors from config file, Error: %+v\n", err)
+			} else if len(colorList) == 0 {
+				// an empty list would leave colors on with nothing to color
+				fmt.Printf("Color config file (%s) lists no colors, colors disabled\n", colorConfig)
 			} else {
 				showColors = true
 				for _, c := range colorList {
@@ -92,9 +455,27 @@ func init() {
 	}
 }
 
+// loadOutput sets the destination from LOG_OUTPUT ("stdout", "stderr" or "file:<dir>") or,
+// when that is unset, from LOG_FILE naming the log file itself, e.g. /var/log/app.log,
+// which is appended to as is and left to Rotate or logrotate rather than rotated by
+// period or size; invalid values are reported and the loggers' own paths are kept
+func loadOutput(output, file string) {
+	switch {
+	case output == "":
+		outputFile = file
+	case strings.EqualFold(output, "stdout"):
+		outputStream = os.Stdout
+	case strings.EqualFold(output, "stderr"):
+		outputStream = os.Stderr
+	case strings.HasPrefix(output, "file:") && len(output) > len("file:"):
+		outputDir = strings.TrimPrefix(output, "file:")
+	default:
+		fmt.Printf("Unable to use %s %q, logging to the configured path\n", outputEnv, output)
+	}
+}
+
 func mapColor(prefix, colorChoice string) (LogLevel, int) {
 	var prefixEnum LogLevel
-	var colorEnum int
 
 	switch strings.ToUpper(prefix) {
 	case "DEBUG":
@@ -106,161 +487,1673 @@ func mapColor(prefix, colorChoice string) (LogLevel, int) {
 	case "ERROR":
 		prefixEnum = LError
 	default:
-		prefixEnum = LogLevel(0)
+		prefixEnum = customLevel(prefix)
 	}
 
+	return prefixEnum, colorEnum(colorChoice)
+}
+
+// colorEnum returns the enum for a color name such as "RED", or -1 if it is unknown
+func colorEnum(colorChoice string) int {
 	switch strings.ToUpper(colorChoice) {
 	case "WHITE":
-		colorEnum = whiteEnum
+		return whiteEnum
 	case "BLUE":
-		colorEnum = blueEnum
+		return blueEnum
 	case "CYAN":
-		colorEnum = cyanEnum
+		return cyanEnum
 	case "GREEN":
-		colorEnum = greenEnum
+		return greenEnum
 	case "YELLOW":
-		colorEnum = yellowEnum
+		return yellowEnum
 	case "RED":
-		colorEnum = redEnum
+		return redEnum
 	case "MAGENTA":
-		colorEnum = magentaEnum
+		return magentaEnum
 	default:
-		colorEnum = -1
+		return -1
 	}
-
-	return prefixEnum, colorEnum
 }
 
-// NewFile attaches a new file for the instance logger to write to
+// NewFile attaches a new file for the instance logger to write to; l is the level, or -1
+// for LOG_LEVEL. d is unused and kept for compatibility: the period comes from Rotation.
 func (i *ILog) NewFile(p string, d, l int) error {
+	Init()
+
+	i.mu.Lock()
+
+	//set LogLevel
+	if l < 0 {
+		i.SetLogLevel(logLevelConfig)
+	} else {
+		i.setLevel(LogLevel(l))
+	}
+	if i.Version == "" {
+		i.Version = logVersion
+	}
+
+	// the first entry past the level filter finds no open file and creates it
+	if i.LazyOpen {
+		i.Path = p
+		i.mu.Unlock()
+		return nil
+	}
+
+	name, err := i.newFile(p)
+	i.mu.Unlock()
+
+	i.rotated(name)
+	return err
+}
+
+// FromStruct finishes a logger built as a struct literal, e.g. &ILog{Path: p, Level: LInfo}:
+// an unset Level comes from LOG_LEVEL, unset Flags become DefaultFlags, and the file is opened
+func FromStruct(i *ILog) (*ILog, error) {
+	Init()
+	if i.Path == "" && i.Ring == nil && outputDir == "" && outputFile == "" && outputStream == nil {
+		return nil, errors.New("ilogger: Path not set")
+	}
+
+	level := int(i.Level)
+	if i.Level == 0 {
+		level = -1
+	}
+	if i.Flags == 0 {
+		i.Flags = DefaultFlags
+	}
+
+	if err := i.NewFile(i.Path, 0, level); err != nil {
+		return nil, err
+	}
+	return i, nil
+}
+
+// newFile opens the file for the current period without locking or touching the level,
+// returning its name, or "" for a stream; callers must hold i.mu and pass the name to
+// rotated once they release it
+func (i *ILog) newFile(p string) (string, error) {
+	// LOG_OUTPUT=file: moves every logger to the configured directory, LOG_FILE to its file's
+	if outputDir != "" {
+		p = outputDir
+	}
+	if outputFile != "" {
+		p = filepath.Dir(outputFile)
+	}
+
+	var stream File
+	switch {
+	case i.Ring != nil:
+		stream = i.Ring
+	case outputStream != nil:
+		stream = outputStream
+	case len(p) == 0 && i.fallback != nil:
+		stream = i.fallback
+	}
+
 	// validate input
-	if len(p) == 0 {
+	if len(p) == 0 && stream == nil {
 		log.Fatalf("ILog filepath not set: %v", "zero length")
 	}
 
 	i.Path = p
-	i.fileDay = d
 
 	// validate directory
-	if err := os.MkdirAll(i.Path, 0755); err != nil {
-		log.Fatalf("Cannot make log path (%v): %v", i.Path, err)
+	if stream == nil {
+		if err := i.retryOpen(func() error { return i.fs().MkdirAll(i.Path, 0755) }); err != nil {
+			return "", fmt.Errorf("cannot make log path (%v): %w", i.Path, err)
+		}
 	}
 
 	// validate / close current file
+	wasOpen := i.logOpen
 	if i.logOpen {
-		if err := i.logFile.Close(); err != nil {
-			log.Printf("unable to close logger (%s): %+v", i.logFile.Name(), err)
+		i.logOpen = false
+		if err := i.flushBuffer(); err != nil {
+			log.Printf("unable to flush logger (%s): %+v", i.logFile.Name(), err)
+		}
+		// stdout and stderr belong to the process, and the Ring to the caller
+		if !i.stream {
+			if err := i.logFile.Close(); err != nil {
+				log.Printf("unable to close logger (%s): %+v", i.logFile.Name(), err)
+			}
 		}
 	}
 
-	//set LogLevel
-	if l < 0 {
-		i.SetLogLevel(logLevelConfig)
-	} else {
-		i.Level = LogLevel(l)
-	}
+	t := i.now().UTC()
+	key := i.Rotation.key(t)
 
-	t := time.Now().UTC()
+	if stream != nil {
+		i.useStream(stream, key)
+		return "", nil
+	}
+	i.stream = false
 
-	ex, err := os.Executable()
-	bex := filepath.Base(ex)
-	name := fmt.Sprintf("%si_%s_%s_%s.log", bex, t.Format("2006"), t.Format("01"), t.Format("02"))
-	name = filepath.Join(i.Path, name)
+	// the sequence restarts each period and skips files already at MaxBytes
+	if !wasOpen || key != i.fileKey {
+		i.fileSeq = 0
+	}
+	flag := os.O_RDWR | os.O_CREATE | os.O_APPEND
+	name := i.fileName(t, i.fileSeq)
+	for outputFile == "" && i.full(name) {
+		i.fileSeq++
+		if i.MaxFiles > 0 && i.fileSeq >= i.MaxFiles {
+			// every file of the period is full, so the oldest is emptied and reused
+			i.fileSeq = i.oldestSeq(t)
+			name = i.fileName(t, i.fileSeq)
+			flag |= os.O_TRUNC
+			break
+		}
+		name = i.fileName(t, i.fileSeq)
+	}
 
-	i.logFile, err = os.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0644)
+	var f File
+	err := i.retryOpen(func() (err error) {
+		f, err = i.fs().OpenFile(name, flag, 0644)
+		return err
+	})
 	if err != nil {
-		log.Fatalf("unable to open logger (%s): %+v", i.logFile.Name(), err)
+		return "", fmt.Errorf("unable to open logger (%s): %w", name, err)
 	}
+	i.logFile = f
+	atomic.StoreUint64(&i.fileBytes, 0)
 
-	//setup golang log variable; we could default to os.Stderr or os.Stdout???
-	i.iLog = log.New(i.logFile, "", log.LstdFlags|log.Lmicroseconds|log.LUTC)
+	i.writeHeader(t)
+	if i.WriteBufferBytes > 0 {
+		i.startBuffer()
+	}
+	if i.CurrentSymlink != "" {
+		i.linkCurrent(name)
+	}
 
 	i.logOpen = true
-	i.fileDay = t.Day()
+	i.fileKey = key
+	i.fileTTY = isTerminal(i.logFile)
 
-	return nil
+	return name, nil
 }
 
-// SetLogLevel allows applications to change the log level with a reload instead of restart
-func (i *ILog) SetLogLevel(level string) {
-	switch strings.ToUpper(level) {
-	case "ERROR":
-		i.Level = LError
-	case "WARN":
-		i.Level = LWarn
-	case "INFO":
-		i.Level = LInfo
-	case "DEBUG":
-		i.Level = LDebug
-	default:
-		i.Level = LError
+// rotated calls OnRotate for a file newFile opened; callers must not hold i.mu, so the
+// hook is free to log
+func (i *ILog) rotated(name string) {
+	if name != "" && i.OnRotate != nil {
+		i.OnRotate(name)
 	}
 }
 
-// Log sends the format and the params to the underlying logger
-func (i *ILog) Log(level LogLevel, formattedString string, params ...interface{}) {
-	if level > i.Level {
+// defaultOpenBackoff is the first wait between open attempts when OpenBackoff is unset
+const defaultOpenBackoff = 50 * time.Millisecond
+
+// retryOpen runs op, retrying failures up to OpenRetries times with a backoff that doubles
+// from OpenBackoff; callers hold i.mu, so writers wait out the retries too
+func (i *ILog) retryOpen(op func() error) error {
+	backoff := i.OpenBackoff
+	if backoff <= 0 {
+		backoff = defaultOpenBackoff
+	}
+
+	err := op()
+	for n := 0; err != nil && n < i.OpenRetries; n++ {
+		time.Sleep(backoff)
+		backoff *= 2
+		err = op()
+	}
+
+	return err
+}
+
+// useStream points the logger at stdout, stderr or the Ring in place of a file; callers
+// must hold i.mu
+func (i *ILog) useStream(f File, key int) {
+	i.logFile = f
+	i.stream = true
+	atomic.StoreUint64(&i.fileBytes, 0)
+
+	if i.WriteBufferBytes > 0 {
+		i.startBuffer()
+	}
+
+	i.logOpen = true
+	i.fileKey = key
+	i.fileTTY = isTerminal(f)
+}
+
+// logHeader is the metadata line written at the top of a new file
+type logHeader struct {
+	Format   string    `json:"format"`
+	Version  int       `json:"version"`
+	Start    time.Time `json:"start"`
+	Hostname string    `json:"hostname"`
+}
+
+// writeHeader writes the Header metadata line and then any HeaderEncoder header if the
+// current file is empty, so appends don't repeat them
+func (i *ILog) writeHeader(t time.Time) {
+	enc := i.encoder()
+	he, hasHeader := enc.(HeaderEncoder)
+	if !i.Header && !hasHeader {
 		return
 	}
 
-	curTime := time.Now().UTC()
-	curDay := curTime.Day()
+	info, err := i.logFile.Stat()
+	if err != nil || info.Size() > 0 {
+		return
+	}
 
-	if i != nil && (!i.logOpen || curDay != i.fileDay) {
-		if err := i.NewFile(i.Path, curDay, int(i.Level)); err != nil {
-			log.Fatalf("Unable to create new ILog: %v", "zero length")
+	var lines [][]byte
+	if i.Header {
+		host, _ := os.Hostname()
+		h, err := json.Marshal(logHeader{Format: formatName(enc), Version: headerVersion, Start: t, Hostname: host})
+		if err != nil {
+			log.Printf("unable to build log header (%s): %+v", i.logFile.Name(), err)
+		} else {
+			lines = append(lines, append(h, '\n'))
 		}
 	}
+	if hasHeader {
+		lines = append(lines, he.FileHeader())
+	}
 
-	if _, err := os.Stat(i.logFile.Name()); err != nil {
-		if err := i.NewFile(i.Path, curDay, int(i.Level)); err != nil {
-			log.Fatalf("Unable to create ILog: %v", "zero length")
+	for _, line := range lines {
+		if i.LengthPrefix {
+			line = frame(line)
 		}
+		n, err := i.logFile.Write(line)
+		if err != nil {
+			log.Printf("unable to write log header (%s): %+v", i.logFile.Name(), err)
+		}
+		i.countBytes(n)
 	}
+}
+
+// linkCurrent points CurrentSymlink at name, replacing the old link atomically with a rename
+func (i *ILog) linkCurrent(name string) {
+	link := filepath.Join(i.Path, i.CurrentSymlink)
+	tmp := link + ".tmp"
 
-	// log message
-	i.iLog.Output(3, fmt.Sprintf(formattedString, params...))
+	// a relative target keeps the link valid if the directory is moved or mounted elsewhere
+	os.Remove(tmp)
+	if err := os.Symlink(filepath.Base(name), tmp); err != nil {
+		log.Printf("unable to link current log (%s): %+v", link, err)
+		return
+	}
+	if err := os.Rename(tmp, link); err != nil {
+		os.Remove(tmp)
+		log.Printf("unable to link current log (%s): %+v", link, err)
+	}
 }
 
-// Fatalf is equivalent to calling Errorf followed by os.Exit(1)
-func (i *ILog) Fatalf(formattedString string, params ...interface{}) {
-	i.Log(LError, formattedString, params...)
-	os.Exit(1)
+// fileName builds the log file name for the period containing t and sequence seq, or
+// returns LOG_FILE's file as named
+func (i *ILog) fileName(t time.Time, seq int) string {
+	if outputFile != "" {
+		return outputFile
+	}
+
+	ex, _ := os.Executable()
+	bex := filepath.Base(ex)
+
+	name := fmt.Sprintf("%si_%s.log", bex, i.Rotation.suffix(t))
+	if seq > 0 {
+		name = fmt.Sprintf("%si_%s_%d.log", bex, i.Rotation.suffix(t), seq)
+	}
+
+	return filepath.Join(i.Path, name)
 }
 
-// Panic is equivalent to calling Errorf followed by panic(params)
-func (i *ILog) Panic(formattedString string, params ...interface{}) {
-	s := fmt.Sprintf(formattedString, params...)
-	i.Log(LError, formattedString, params...)
-	panic(s)
+// oldestSeq returns the sequence of the least recently written file among the MaxFiles
+// files of the period containing t
+func (i *ILog) oldestSeq(t time.Time) int {
+	oldest := 0
+	var oldestTime time.Time
+	for seq := 0; seq < i.MaxFiles; seq++ {
+		info, err := i.fs().Stat(i.fileName(t, seq))
+		if err != nil {
+			return seq
+		}
+		if seq == 0 || info.ModTime().Before(oldestTime) {
+			oldest, oldestTime = seq, info.ModTime()
+		}
+	}
+
+	return oldest
 }
 
-// Error log
-func (i *ILog) Error(err error) {
-	i.Log(LError, err.Error())
+// full reports whether the named file has reached MaxBytes
+func (i *ILog) full(name string) bool {
+	if i.MaxBytes <= 0 {
+		return false
+	}
+
+	info, err := i.fs().Stat(name)
+	return err == nil && info.Size() >= i.MaxBytes
 }
 
-// Mandatory always logs regardless of logging level
-func (i *ILog) Mandatory(formattedString string, params ...interface{}) {
-	i.Log(LMandatory, formattedString, params...)
+// Sync flushes every scoped output and sink that can be flushed and commits the current
+// file to disk; all of them are attempted, and the error lists each one that failed
+func (i *ILog) Sync() error {
+	errs := i.flushOutputs()
+
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	if i.logOpen {
+		if err := i.flushBuffer(); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", i.logFile.Name(), err))
+		}
+		// pipes and terminals can't be synced, so streams stop at the flush
+		if !i.stream {
+			if err := i.logFile.Sync(); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", i.logFile.Name(), err))
+			}
+		}
+	}
+
+	return errs.err()
 }
 
-// Errorf log
-func (i *ILog) Errorf(formattedString string, params ...interface{}) {
-	i.Log(LError, errorPrefix+formattedString, params...)
+// Close flushes the outputs like Sync, stops any level file watchers and closes the
+// current log file; logging again afterwards opens a new file
+func (i *ILog) Close() error {
+	i.flushSamples()
+	errs := i.flushOutputs()
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	for _, w := range i.watchers {
+		if err := w.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("level file watcher: %w", err))
+		}
+	}
+	i.watchers = nil
+
+	if i.flushStop != nil {
+		close(i.flushStop)
+		i.flushStop = nil
+	}
+
+	if i.logOpen {
+		i.logOpen = false
+		if err := i.flushBuffer(); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", i.logFile.Name(), err))
+		}
+		if !i.stream {
+			if err := i.logFile.Close(); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", i.logFile.Name(), err))
+			}
+		}
+	}
+
+	return errs.err()
 }
 
-// Warn log
-func (i *ILog) Warn(formattedString string, params ...interface{}) {
-	i.Log(LWarn, warnPrefix+formattedString, params...)
+// Rotate starts a new file on demand, independent of Rotation and MaxBytes: the current
+// file is flushed, closed and renamed with a timestamp suffix, then a fresh file is opened
+// under the original name. It does nothing if no file is open yet, and only flushes when
+// LOG_OUTPUT sends the logs to stdout or stderr.
+func (i *ILog) Rotate() error {
+	i.mu.Lock()
+	name, err := i.rotate()
+	i.mu.Unlock()
+
+	i.rotated(name)
+	return err
 }
 
-// Info log
-func (i *ILog) Info(formattedString string, params ...interface{}) {
-	i.Log(LInfo, infoPrefix+formattedString, params...)
+// rotate does the work of Rotate, returning the name of the fresh file; callers must hold i.mu
+func (i *ILog) rotate() (string, error) {
+	if !i.logOpen {
+		return "", nil
+	}
+	if i.stream {
+		return "", i.flushBuffer()
+	}
+
+	var errs multiError
+	name := i.logFile.Name()
+	if err := i.flushBuffer(); err != nil {
+		errs = append(errs, fmt.Errorf("%s: %w", name, err))
+	}
+	i.logOpen = false
+	if err := i.logFile.Close(); err != nil {
+		errs = append(errs, fmt.Errorf("%s: %w", name, err))
+	}
+
+	stamp := time.Now().UTC().Format("20060102T150405.000000000")
+	if err := i.fs().Rename(name, strings.TrimSuffix(name, ".log")+"."+stamp+".log"); err != nil {
+		errs = append(errs, err)
+	}
+
+	// the renamed file frees its name, so the same period and sequence open it fresh
+	fresh, err := i.newFile(i.Path)
+	if err != nil {
+		errs = append(errs, err)
+	}
+
+	return fresh, errs.err()
 }
 
-// Debug log
-func (i *ILog) Debug(formattedString string, params ...interface{}) {
-	i.Log(LDebug, debugPrefix+formattedString, params...)
+// now returns the time rotation is decided by
+func (i *ILog) now() time.Time {
+	if i.clock != nil {
+		return i.clock()
+	}
+	return time.Now()
+}
+
+// needsFile reports whether a new file must be opened before writing in period key
+func (i *ILog) needsFile(key int) bool {
+	if i.logOpen && i.stream {
+		return false
+	}
+	// LOG_FILE's file keeps its name, so only Rotate starts another
+	if outputFile != "" {
+		return !i.logOpen
+	}
+	if !i.logOpen || key != i.fileKey {
+		return true
+	}
+
+	info, err := i.fs().Stat(i.logFile.Name())
+	if err != nil {
+		return true
+	}
+
+	return i.MaxBytes > 0 && info.Size() >= i.MaxBytes
+}
+
+// SetLogLevel allows applications to change the log level with a reload instead of restart.
+// Entries are filtered when they are logged, not when they reach the file, so those still
+// held by WriteBufferBytes are written as accepted under the level in force at the time.
+func (i *ILog) SetLogLevel(level string) {
+	l := LError
+	switch strings.ToUpper(level) {
+	case "ERROR":
+		l = LError
+	case "WARN":
+		l = LWarn
+	case "INFO":
+		l = LInfo
+	case "DEBUG":
+		l = LDebug
+	default:
+		if c := customLevel(level); c != 0 {
+			l = c
+		}
+	}
+
+	i.setLevel(l)
+}
+
+// setLevel changes Level under filterMu, so watchers can change it while other goroutines log
+func (i *ILog) setLevel(l LogLevel) {
+	i.filterMu.Lock()
+	i.Level = l
+	i.filterMu.Unlock()
+}
+
+// level returns Level and the EnableLevels mask
+func (i *ILog) level() (LogLevel, LogLevel) {
+	i.filterMu.RLock()
+	defer i.filterMu.RUnlock()
+
+	return i.Level, i.mask
+}
+
+// EnableLevels switches to bitmask filtering, so only the given levels are written
+// (e.g. LError|LDebug) instead of everything up to Level; Mandatory always logs.
+// A custom level is selectable only if its value is a single bit of its own; others,
+// registered to sit between the built-in levels, are filtered out in this mode.
+// EnableLevels(0) returns to threshold filtering.
+func (i *ILog) EnableLevels(levels LogLevel) {
+	i.filterMu.Lock()
+	i.mask = levels
+	i.filterMu.Unlock()
+}
+
+// RegisterLevel adds a custom level usable with Logf, SetLogLevel and the color config.
+// Any value orders it against the built-in levels for threshold filtering; EnableLevels
+// can only select it if the value is an unused single bit, such as 32.
+func RegisterLevel(name string, value LogLevel) {
+	Init()
+	name = strings.ToUpper(name)
+
+	levelMu.Lock()
+	customLevels[name] = value
+	levelPrefixes[value] = name + " - "
+	levelNames[value] = name
+	levelMu.Unlock()
+
+	// colors are loaded before levels can be registered, so pick up any entry for this one now
+	for _, c := range colorList {
+		if strings.ToUpper(c.Level) == name {
+			_, colorEnum := mapColor(c.Level, c.Color)
+			levelMu.Lock()
+			colorMap[value] = colorEnum
+			levelMu.Unlock()
+		}
+	}
+}
+
+// Color settings decide when the colors from LOG_COLOR_CONFIG are applied
+const (
+	// ColorAuto colors output only while the current log file is a terminal, so escape
+	// codes never end up in files; it is the default
+	ColorAuto = ColorSetting(iota)
+	// ColorAlways colors output whenever LOG_COLOR_CONFIG sets colors, terminal or not
+	ColorAlways
+	// ColorNever never colors output
+	ColorNever
+)
+
+// ColorSetting decides when configured colors are applied
+type ColorSetting uint8
+
+// String returns "auto", "always" or "never"
+func (c ColorSetting) String() string {
+	switch c {
+	case ColorAuto:
+		return "auto"
+	case ColorAlways:
+		return "always"
+	case ColorNever:
+		return "never"
+	default:
+		return fmt.Sprintf("ColorSetting(%d)", uint8(c))
+	}
+}
+
+// ColorEnabled reports whether the logger currently colors its text output: colors must
+// be configured through LOG_COLOR_CONFIG and allowed by Color for the current file
+func (i *ILog) ColorEnabled() bool {
+	Init()
+
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	return i.colorEnabled()
+}
+
+// colorEnabled is ColorEnabled for callers already holding mu
+func (i *ILog) colorEnabled() bool {
+	if !showColors {
+		return false
+	}
+
+	switch i.Color {
+	case ColorAlways:
+		return true
+	case ColorNever:
+		return false
+	default:
+		return i.logOpen && i.fileTTY
+	}
+}
+
+// isTerminal reports whether f is a character device such as a terminal
+func isTerminal(f File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// levelColor returns the escape code configured for level, or "" when it isn't colored
+func levelColor(level LogLevel) string {
+	if !showColors {
+		return ""
+	}
+
+	levelMu.RLock()
+	defer levelMu.RUnlock()
+
+	return colorCodes[colorMap[level]]
+}
+
+// customLevel returns the registered level for name, or 0 if there is none
+func customLevel(name string) LogLevel {
+	levelMu.RLock()
+	defer levelMu.RUnlock()
+
+	return customLevels[strings.ToUpper(name)]
+}
+
+// levelPrefix returns the message prefix for a standard or registered level
+func levelPrefix(level LogLevel) string {
+	levelMu.RLock()
+	defer levelMu.RUnlock()
+
+	return levelPrefixes[level]
+}
+
+// Log sends the format and the params to the underlying logger
+func (i *ILog) Log(level LogLevel, formattedString string, params ...interface{}) {
+	i.log(level, false, formattedString, params...)
+}
+
+// log filters and formats a message into an Entry and writes it; prefixed controls
+// whether text output carries the level's prefix
+func (i *ILog) log(level LogLevel, prefixed bool, formattedString string, params ...interface{}) {
+	if !i.enabled(level) {
+		return
+	}
+
+	// skip entry, log and the exported method to reach the caller
+	i.write(i.entry(3, time.Now(), level, prefixed, formattedString, params))
+}
+
+// LogAt logs with the level's prefix like Logf, but stamps the entry with t instead of
+// the current time, e.g. for replayed or client-side events
+func (i *ILog) LogAt(t time.Time, level LogLevel, formattedString string, params ...interface{}) {
+	if !i.enabled(level) {
+		return
+	}
+
+	// skip entry and LogAt to reach the caller
+	i.write(i.entry(2, t, level, true, formattedString, params))
+}
+
+// LogWithCaller logs with the level's prefix like Logf, but reports file:line as the
+// caller instead of looking it up, for adapters that already know the real call site,
+// e.g. from another logging framework or a recovered panic. The location costs no lookup,
+// so it is kept even with NoCaller, and text output shows it without the file flags.
+func (i *ILog) LogWithCaller(level LogLevel, file string, line int, formattedString string, params ...interface{}) {
+	if !i.enabled(level) {
+		return
+	}
+
+	e := i.entry(-1, time.Now(), level, true, formattedString, params)
+	e.Caller = fmt.Sprintf("%s:%d", file, line)
+	e.callerSet = true
+	i.write(e)
+}
+
+// entry builds the Entry for a message; skip is the runtime.Caller depth of the logging call,
+// or negative to leave the caller for the logging call to set
+func (i *ILog) entry(skip int, t time.Time, level LogLevel, prefixed bool, formattedString string, params []interface{}) Entry {
+	e := Entry{
+		Time:      t,
+		Level:     level,
+		Message:   safeSprintf(formattedString, i.bytesParams(i.errorParam(params))...),
+		Component: i.Component,
+		Tags:      i.Tags,
+		noPrefix:  !prefixed,
+	}
+	if skip >= 0 && i.wantCaller() {
+		if _, file, line, ok := runtime.Caller(skip); ok {
+			e.Caller = fmt.Sprintf("%s:%d", file, line)
+		}
+	}
+	if i.GoroutineID {
+		e.Fields = map[string]interface{}{"goroutine": goroutineID()}
+	}
+	if n := len(params); n > 0 {
+		if err, ok := params[n-1].(error); ok {
+			if code := errorCode(err); code != "" {
+				e.Fields = withField(e.Fields, "error_code", code)
+			}
+			// text output already carries the chain in the message
+			if i.structured() {
+				e.Fields = withField(e.Fields, "error", safeSprintf("%+v", err))
+			}
+		}
+	}
+
+	return e
+}
+
+// write filters entries, then encodes them and writes them to the current file in one
+// write, rotating first when needed
+func (i *ILog) write(entries ...Entry) {
+	if i.SkipEmpty {
+		if entries = nonEmpty(entries); len(entries) == 0 {
+			return
+		}
+	}
+	// escalated entries count as new messages for DedupWindow, so the first gets through
+	if i.Escalation.After > 0 {
+		i.escalate(entries)
+	}
+	if i.Sampler != nil {
+		if entries = i.sampleWith(entries); len(entries) == 0 {
+			return
+		}
+	}
+	if len(i.RateLimits) > 0 {
+		if entries = i.throttle(entries); len(entries) == 0 {
+			return
+		}
+	}
+	if i.DedupWindow > 0 {
+		if entries = i.dedupe(entries); len(entries) == 0 {
+			return
+		}
+	}
+	if i.SampleWindow > 0 {
+		if entries = i.sample(entries); len(entries) == 0 {
+			return
+		}
+	}
+
+	i.emit(entries...)
+}
+
+// emit writes entries that made it through the filters, then runs OnLog once no lock is
+// held so the hook may log itself
+func (i *ILog) emit(entries ...Entry) {
+	if !i.writeEntries(entries) || i.OnLog == nil {
+		return
+	}
+
+	for _, e := range entries {
+		i.callOnLog(e)
+	}
+}
+
+// writeEntries writes entries to the file and outputs, reporting whether a file was open
+func (i *ILog) writeEntries(entries []Entry) bool {
+	// rotation follows the wall clock, not the entry's own time
+	curKey := i.Rotation.key(i.now().UTC())
+
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	if i.needsFile(curKey) {
+		// re-check under the write lock so only one goroutine rotates per boundary
+		i.mu.RUnlock()
+		i.mu.Lock()
+		var name string
+		var err error
+		if i.needsFile(curKey) {
+			name, err = i.newFile(i.Path)
+		}
+		i.mu.Unlock()
+		i.rotated(name)
+		i.mu.RLock()
+
+		// another writer may have failed to open a file between the locks as well
+		if !i.logOpen {
+			if err != nil {
+				log.Printf("unable to create new ILog, dropping %d entries: %+v", len(entries), err)
+			}
+			return false
+		}
+	}
+
+	lines := i.encodeAndWrite(entries)
+	i.writeOutputs(entries, lines)
+	if i.MirrorStdlib {
+		i.mirrorStdlib(entries)
+	}
+
+	return true
+}
+
+// encodeAndWrite numbers, encodes and writes entries to the file, returning the lines
+func (i *ILog) encodeAndWrite(entries []Entry) [][]byte {
+	if i.Sequence {
+		// count and write together so sequence numbers land in the file in order
+		i.seqMu.Lock()
+		defer i.seqMu.Unlock()
+		for n := range entries {
+			i.seq++
+			entries[n].Seq = i.seq
+		}
+	}
+
+	enc := i.encoder()
+	lines := make([][]byte, len(entries))
+	for n, e := range entries {
+		if e.Labels == nil {
+			e.Labels = i.entryLabels()
+			entries[n].Labels = e.Labels
+		}
+		if i.Version != "" {
+			e.Fields = withField(e.Fields, "version", i.Version)
+			entries[n].Fields = e.Fields
+		}
+		if i.BytesFormat != BytesRaw {
+			e.Fields = i.bytesFields(e.Fields)
+			entries[n].Fields = e.Fields
+		}
+		lines[n] = i.redactLine(enc.Encode(e))
+		if len(i.redact) > 0 {
+			// sinks, outputs with their own encoder and OnLog see the entry rather than
+			// the line, so mask its text too
+			entries[n] = i.redactEntry(entries[n])
+		}
+		if i.LengthPrefix {
+			lines[n] = frame(lines[n])
+		}
+	}
+
+	// everything goes out in a single write; with O_APPEND, POSIX keeps writes up to
+	// PIPE_BUF from interleaving with other processes appending to the same file
+	if len(lines) == 1 {
+		i.writeLine(lines[0])
+	} else {
+		i.writeLine(bytes.Join(lines, nil))
+	}
+
+	return lines
+}
+
+// RedactPatterns masks every match of patterns with "[REDACTED]" in each rendered line,
+// e.g. card numbers or tokens inside free-form messages, and in the message, component,
+// tags and string fields that sinks and OnLog receive; it replaces any earlier patterns,
+// and nil turns redaction off
+func (i *ILog) RedactPatterns(patterns []*regexp.Regexp) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	i.redact = patterns
+}
+
+// SetLabels attaches labels to every entry, over any from LOG_LABEL_* variables with the
+// same name; the JSON encoders write them as a "labels" object. nil keeps only the env labels.
+func (i *ILog) SetLabels(labels map[string]string) {
+	Init()
+
+	merged := make(map[string]string, len(envLabels)+len(labels))
+	for k, v := range envLabels {
+		merged[k] = v
+	}
+	for k, v := range labels {
+		merged[k] = v
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	i.labels = merged
+}
+
+// entryLabels returns the labels for entries that bring none of their own; callers must hold i.mu
+func (i *ILog) entryLabels() map[string]string {
+	if i.labels == nil {
+		return envLabels
+	}
+	return i.labels
+}
+
+// redactLine applies the redaction patterns to line; callers must hold i.mu
+func (i *ILog) redactLine(line []byte) []byte {
+	for _, re := range i.redact {
+		line = re.ReplaceAllLiteral(line, []byte(redacted))
+	}
+	return line
+}
+
+// redactEntry applies the redaction patterns to the message, component, tags and string
+// field values of e, copying the tags and fields rather than changing the caller's; callers
+// must hold i.mu
+func (i *ILog) redactEntry(e Entry) Entry {
+	e.Message = i.redactString(e.Message)
+	e.Component = i.redactString(e.Component)
+
+	if len(e.Tags) > 0 {
+		tags := make([]string, len(e.Tags))
+		for n, tag := range e.Tags {
+			tags[n] = i.redactString(tag)
+		}
+		e.Tags = tags
+	}
+
+	if len(e.Fields) > 0 {
+		fields := make(map[string]interface{}, len(e.Fields))
+		for k, v := range e.Fields {
+			if s, ok := v.(string); ok {
+				v = i.redactString(s)
+			}
+			fields[k] = v
+		}
+		e.Fields = fields
+	}
+
+	return e
+}
+
+// redactString is redactLine for a string
+func (i *ILog) redactString(s string) string {
+	return string(i.redactLine([]byte(s)))
+}
+
+// LogTags logs with the level's prefix like Logf, adding tags to the logger's own
+func (i *ILog) LogTags(level LogLevel, tags []string, formattedString string, params ...interface{}) {
+	if !i.enabled(level) {
+		return
+	}
+
+	// skip entry and LogTags to reach the caller
+	e := i.entry(2, time.Now(), level, true, formattedString, params)
+	e.Tags = mergeTags(i.Tags, tags)
+	i.write(e)
+}
+
+// mergeTags returns parent followed by the tags of own not already in it
+func mergeTags(parent, own []string) []string {
+	if len(own) == 0 {
+		return parent
+	}
+	if len(parent) == 0 {
+		return own
+	}
+
+	tags := make([]string, len(parent), len(parent)+len(own))
+	copy(tags, parent)
+	for _, t := range own {
+		dup := false
+		for _, p := range parent {
+			if p == t {
+				dup = true
+				break
+			}
+		}
+		if !dup {
+			tags = append(tags, t)
+		}
+	}
+
+	return tags
+}
+
+// frame replaces the trailing newline of line with a 4-byte big-endian length prefix
+func frame(line []byte) []byte {
+	line = bytes.TrimSuffix(line, []byte{'\n'})
+
+	framed := make([]byte, 4+len(line))
+	binary.BigEndian.PutUint32(framed, uint32(len(line)))
+	copy(framed[4:], line)
+
+	return framed
+}
+
+// withField returns a copy of fields with key set to value, leaving the caller's map untouched
+func withField(fields map[string]interface{}, key string, value interface{}) map[string]interface{} {
+	f := make(map[string]interface{}, len(fields)+1)
+	for k, v := range fields {
+		f[k] = v
+	}
+	f[key] = value
+
+	return f
+}
+
+// nonEmpty returns the entries whose message has more than whitespace, reusing entries
+func nonEmpty(entries []Entry) []Entry {
+	kept := entries[:0]
+	for _, e := range entries {
+		if strings.TrimSpace(e.Message) != "" {
+			kept = append(kept, e)
+		}
+	}
+	return kept
+}
+
+// LogBatch writes related entries together: they are filtered by their own levels and
+// written contiguously in one write under a single lock. Entries without a Time get the
+// current time, and those without a Component get the logger's.
+func (i *ILog) LogBatch(entries []Entry) {
+	now := time.Now()
+
+	batch := make([]Entry, 0, len(entries))
+	for _, e := range entries {
+		if !i.enabled(e.Level) {
+			continue
+		}
+		if e.Time.IsZero() {
+			e.Time = now
+		}
+		if e.Component == "" {
+			e.Component = i.Component
+		}
+		e.Tags = mergeTags(i.Tags, e.Tags)
+		batch = append(batch, e)
+	}
+
+	if len(batch) > 0 {
+		i.write(batch...)
+	}
+}
+
+// writeLine writes one encoded entry to the file, under flock when it is too large to be atomic
+func (i *ILog) writeLine(line []byte) {
+	// buffered lines reach the file in later writes, so there is nothing to lock here
+	if i.WriteBufferBytes > 0 {
+		i.bufferLine(line)
+		return
+	}
+
+	if i.LockLargeWrites && len(line) > pipeBuf {
+		// flock belongs to the open file, which every goroutine here shares, so it only
+		// keeps other processes out; lockMu keeps this process's writers apart
+		i.lockMu.Lock()
+		defer i.lockMu.Unlock()
+		if err := lockFile(i.logFile); err != nil {
+			log.Printf("unable to lock log (%s): %+v", i.logFile.Name(), err)
+		} else {
+			defer unlockFile(i.logFile)
+		}
+	}
+
+	n, err := i.logFile.Write(line)
+	if err != nil {
+		n += i.writeFailed(line[n:], err)
+	}
+	i.countBytes(n)
+}
+
+// Policies for OnWriteError
+const (
+	// WriteErrorDrop reports the failure through the log package and drops the line
+	WriteErrorDrop = WriteErrorPolicy(iota)
+	// WriteErrorStderr reports the failure and writes the rest of the line to stderr
+	WriteErrorStderr
+	// WriteErrorRetry writes the rest of the line again, up to OpenRetries times (at
+	// least once) with the OpenBackoff wait, before reporting it and dropping it
+	WriteErrorRetry
+)
+
+// WriteErrorPolicy selects how a failed write to the log file is handled
+type WriteErrorPolicy uint8
+
+// String returns "drop", "stderr" or "retry"
+func (p WriteErrorPolicy) String() string {
+	switch p {
+	case WriteErrorStderr:
+		return "stderr"
+	case WriteErrorRetry:
+		return "retry"
+	default:
+		return "drop"
+	}
+}
+
+// writeFailed handles rest, the part of a line the file did not take, by OnWriteError
+// and returns how much of it the file took on a retry
+func (i *ILog) writeFailed(rest []byte, err error) int {
+	written := 0
+	if i.OnWriteError == WriteErrorRetry {
+		backoff := i.OpenBackoff
+		if backoff <= 0 {
+			backoff = defaultOpenBackoff
+		}
+
+		for n := 0; err != nil && (n == 0 || n < i.OpenRetries); n++ {
+			time.Sleep(backoff)
+			backoff *= 2
+
+			var w int
+			w, err = i.logFile.Write(rest[written:])
+			written += w
+		}
+		if err == nil {
+			return written
+		}
+	}
+
+	log.Printf("unable to write log (%s): %+v", i.logFile.Name(), err)
+	if i.OnWriteError == WriteErrorStderr {
+		if _, err := os.Stderr.Write(rest); err != nil {
+			log.Printf("unable to write log to stderr: %+v", err)
+		}
+	}
+
+	return written
+}
+
+// countBytes adds n to the per-file and per-day byte counters, starting the day over
+// when the UTC date has changed
+func (i *ILog) countBytes(n int) {
+	today := int64(RotateDaily.key(i.now().UTC()))
+	if atomic.LoadInt64(&i.day) != today && atomic.SwapInt64(&i.day, today) != today {
+		atomic.StoreUint64(&i.dayBytes, 0)
+	}
+
+	atomic.AddUint64(&i.fileBytes, uint64(n))
+	atomic.AddUint64(&i.dayBytes, uint64(n))
+}
+
+// BytesWritten returns the bytes this logger has written to the current file, header
+// included; it starts over each time a new file is opened
+func (i *ILog) BytesWritten() uint64 {
+	return atomic.LoadUint64(&i.fileBytes)
+}
+
+// BytesToday returns the bytes this logger has written across all files since midnight UTC
+func (i *ILog) BytesToday() uint64 {
+	if atomic.LoadInt64(&i.day) != int64(RotateDaily.key(i.now().UTC())) {
+		return 0
+	}
+	return atomic.LoadUint64(&i.dayBytes)
+}
+
+// bufferFlushInterval is how often buffered writes are flushed when nothing else flushes them
+const bufferFlushInterval = time.Second
+
+// startBuffer points the write buffer at the newly opened file and starts the periodic
+// flush; callers must hold i.mu for writing
+func (i *ILog) startBuffer() {
+	i.bufMu.Lock()
+	i.bufOut = &bufferTarget{f: i.logFile}
+	if i.buf == nil {
+		i.buf = bufio.NewWriterSize(i.bufOut, i.WriteBufferBytes)
+	} else {
+		i.buf.Reset(i.bufOut)
+	}
+	i.bufMu.Unlock()
+
+	if i.flushStop == nil {
+		i.flushStop = make(chan struct{})
+		go i.flushEvery(bufferFlushInterval, i.flushStop)
+	}
+}
+
+// flushEvery flushes the write buffer on each tick until stop is closed
+func (i *ILog) flushEvery(d time.Duration, stop chan struct{}) {
+	ticker := time.NewTicker(d)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			i.mu.RLock()
+			if i.logOpen {
+				// flushBuffer has already reported any failure
+				i.flushBuffer()
+			}
+			i.mu.RUnlock()
+		}
+	}
+}
+
+// bufferLine adds line to the write buffer, first flushing what is there if line won't
+// fit, so every write to the file still ends on a line boundary
+func (i *ILog) bufferLine(line []byte) {
+	i.bufMu.Lock()
+	defer i.bufMu.Unlock()
+
+	if len(line) > i.buf.Available() && i.buf.Buffered() > 0 {
+		if err := i.buf.Flush(); err != nil {
+			i.bufferFailed(err)
+		}
+	}
+	// a line larger than the buffer goes straight to the file, through bufOut
+	n, err := i.buf.Write(line)
+	if err != nil {
+		i.bufferFailed(err)
+	}
+	i.countBytes(n)
+}
+
+// flushBuffer writes out anything in the write buffer; callers must hold i.mu
+func (i *ILog) flushBuffer() error {
+	i.bufMu.Lock()
+	defer i.bufMu.Unlock()
+
+	if i.buf == nil || i.buf.Buffered() == 0 {
+		return nil
+	}
+	if err := i.buf.Flush(); err != nil {
+		return i.bufferFailed(err)
+	}
+	return nil
+}
+
+// bufferFailed hands the part of a flush the file did not take to OnWriteError and resets
+// the buffer, since a bufio.Writer otherwise fails every write after its first error; it
+// returns err unless a retry got the rest through. Callers must hold bufMu.
+func (i *ILog) bufferFailed(err error) error {
+	rest := i.bufOut.failed
+	i.bufOut.failed = nil
+	i.buf.Reset(i.bufOut)
+
+	if i.writeFailed(rest, err) == len(rest) {
+		return nil
+	}
+	return err
+}
+
+// bufferTarget is the file under the write buffer, keeping what a failed write left unwritten
+type bufferTarget struct {
+	f      File
+	failed []byte
+}
+
+// Write implements io.Writer
+func (t *bufferTarget) Write(p []byte) (int, error) {
+	n, err := t.f.Write(p)
+	if err != nil {
+		t.failed = append(t.failed[:0], p[n:]...)
+	}
+	return n, err
+}
+
+// callOnLog runs the OnLog hook, keeping a panicking hook from taking down the caller
+func (i *ILog) callOnLog(e Entry) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("ilogger OnLog hook panicked: %v", r)
+		}
+	}()
+
+	i.OnLog(e.Level, e.Message)
+}
+
+// wantCaller reports whether entries need runtime.Caller resolved: text output only shows it
+// for the file flags, other encoders always get it unless NoCaller turns it off
+func (i *ILog) wantCaller() bool {
+	if i.NoCaller {
+		return false
+	}
+
+	// only the Encoder and Flags matter here, so this doesn't build the text encoder,
+	// whose colors read file state that rotation changes under mu
+	switch enc := i.Encoder.(type) {
+	case nil:
+		return TextEncoder{Flags: i.Flags}.flags()&(log.Lshortfile|log.Llongfile) != 0
+	case TextEncoder:
+		return enc.flags()&(log.Lshortfile|log.Llongfile) != 0
+	case *TextEncoder:
+		return enc.flags()&(log.Lshortfile|log.Llongfile) != 0
+	default:
+		return true
+	}
+}
+
+// structured reports whether the encoder writes fields as data of their own, unlike text
+func (i *ILog) structured() bool {
+	switch i.Encoder.(type) {
+	case nil, TextEncoder, *TextEncoder:
+		return false
+	default:
+		return true
+	}
+}
+
+// encoder returns the configured Encoder or a TextEncoder using the logger's text options;
+// both live on the logger rather than the file, so rotation never resets them. The text
+// encoder's colors depend on the open file, so callers must hold i.mu.
+func (i *ILog) encoder() Encoder {
+	if i.Encoder == nil {
+		enc := TextEncoder{Flags: i.Flags, Prefix: i.Prefix, AllowControls: i.AllowControls, ColorMode: i.ColorMode, NoColor: !i.colorEnabled(), CompactLevel: i.CompactLevel, FieldOrder: i.FieldOrder}
+		if !enc.NoColor && i.fileTTY {
+			enc.KeywordColors = i.KeywordColors
+		}
+		return enc
+	}
+	return i.Encoder
+}
+
+// goroutineID parses the current goroutine's ID from the "goroutine N [" stack header
+func goroutineID() uint64 {
+	var buf [64]byte
+	n := runtime.Stack(buf[:], false)
+	s := strings.TrimPrefix(string(buf[:n]), "goroutine ")
+	if i := strings.IndexByte(s, ' '); i > 0 {
+		s = s[:i]
+	}
+
+	id, _ := strconv.ParseUint(s, 10, 64)
+	return id
+}
+
+// enabled reports whether a message at level would be written
+func (i *ILog) enabled(level LogLevel) bool {
+	Init()
+
+	// checked first so disabled logging never reaches the level filter or formatting
+	if logDisabled {
+		return false
+	}
+
+	threshold, mask := i.level()
+	if mask != 0 {
+		// only single-bit levels can be selected, so one between two others, such as
+		// LogLevel(6) sharing LError and LWarn's bits, is never taken for either
+		return level == LMandatory || level&(level-1) == 0 && level&mask != 0
+	}
+	return level <= threshold
+}
+
+// panicPlaceholder stands in for a message whose params panicked while being formatted
+const panicPlaceholder = "!PANIC"
+
+// safeSprintf is fmt.Sprintf that survives panicking params: fmt recovers a panicking
+// String, Error or Format method itself and prints "%!v(PANIC=...)" in its place, and
+// a panic value that panics again when printed escapes it altogether. Either way the
+// message becomes the format followed by panicPlaceholder, and a warning is logged.
+func safeSprintf(format string, params ...interface{}) (s string) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("ilogger message formatting panicked: %q", format)
+			s = format + " " + panicPlaceholder
+		}
+	}()
+
+	s = fmt.Sprintf(format, params...)
+	if strings.Contains(s, "(PANIC=") && paramPanics(params) {
+		log.Printf("ilogger message formatting panicked: %q", format)
+		return format + " " + panicPlaceholder
+	}
+	return s
+}
+
+// paramPanics reports whether formatting any of params panics, telling fmt's own panic
+// report apart from params that merely contain its text
+func paramPanics(params []interface{}) bool {
+	for _, p := range params {
+		var panicked bool
+		switch v := p.(type) {
+		case fmt.Formatter:
+			panicked = strings.Contains(fmt.Sprintf("%v", v), "(PANIC=")
+		case error:
+			panicked = callPanics(func() { _ = v.Error() })
+		case fmt.Stringer:
+			panicked = callPanics(func() { _ = v.String() })
+		}
+		if panicked {
+			return true
+		}
+	}
+	return false
+}
+
+// callPanics reports whether f panics
+func callPanics(f func()) (panicked bool) {
+	defer func() {
+		if recover() != nil {
+			panicked = true
+		}
+	}()
+
+	f()
+	return false
+}
+
+// errorParam renders a trailing error param with %+v so its full chain is kept
+func (i *ILog) errorParam(params []interface{}) []interface{} {
+	n := len(params)
+	if n == 0 {
+		return params
+	}
+
+	err, ok := params[n-1].(error)
+	if !ok {
+		return params
+	}
+
+	s := safeSprintf("%+v", err)
+	if i.ErrorType {
+		s = fmt.Sprintf("%s (%T)", s, err)
+	}
+
+	// copy so the caller's slice is left untouched
+	p := make([]interface{}, n)
+	copy(p, params)
+	p[n-1] = s
+
+	return p
+}
+
+// Byte slice renderings for BytesFormat
+const (
+	// BytesRaw leaves []byte to fmt and the encoder
+	BytesRaw = BytesFormat(iota)
+	// BytesHex renders []byte as lowercase hex
+	BytesHex
+	// BytesBase64 renders []byte as standard padded base64
+	BytesBase64
+)
+
+// BytesFormat is how []byte params and fields are rendered
+type BytesFormat uint8
+
+// render returns b in the format, or b itself for BytesRaw
+func (f BytesFormat) render(b []byte) interface{} {
+	switch f {
+	case BytesHex:
+		return hex.EncodeToString(b)
+	case BytesBase64:
+		return base64.StdEncoding.EncodeToString(b)
+	default:
+		return b
+	}
+}
+
+// bytesParams renders []byte params with BytesFormat, copying so the caller's slice is left untouched
+func (i *ILog) bytesParams(params []interface{}) []interface{} {
+	if i.BytesFormat == BytesRaw {
+		return params
+	}
+
+	var p []interface{}
+	for n, param := range params {
+		b, ok := param.([]byte)
+		if !ok {
+			continue
+		}
+		if p == nil {
+			p = make([]interface{}, len(params))
+			copy(p, params)
+		}
+		p[n] = i.BytesFormat.render(b)
+	}
+	if p == nil {
+		return params
+	}
+
+	return p
+}
+
+// bytesFields renders []byte field values with BytesFormat, copying fields if any change
+func (i *ILog) bytesFields(fields map[string]interface{}) map[string]interface{} {
+	var f map[string]interface{}
+	for k, v := range fields {
+		b, ok := v.([]byte)
+		if !ok {
+			continue
+		}
+		if f == nil {
+			f = make(map[string]interface{}, len(fields))
+			for k, v := range fields {
+				f[k] = v
+			}
+		}
+		f[k] = i.BytesFormat.render(b)
+	}
+	if f == nil {
+		return fields
+	}
+
+	return f
+}
+
+// Logf logs at any level, including registered custom levels, with that level's prefix
+func (i *ILog) Logf(level LogLevel, formattedString string, params ...interface{}) {
+	i.log(level, true, formattedString, params...)
+}
+
+// LogOnce logs at level only the first time it is called with key; later calls are dropped
+func (i *ILog) LogOnce(key string, level LogLevel, formattedString string, params ...interface{}) {
+	// a filtered call doesn't use up the key
+	if !i.enabled(level) {
+		return
+	}
+	if _, seen := i.once.LoadOrStore(key, struct{}{}); seen {
+		return
+	}
+
+	i.log(level, true, formattedString, params...)
+}
+
+// Fatalf is equivalent to calling Errorf followed by os.Exit(FatalCode); the logger is
+// synced first, since os.Exit skips deferred Close calls
+func (i *ILog) Fatalf(formattedString string, params ...interface{}) {
+	i.fatal(i.fatalCode(), formattedString, params)
+}
+
+// Fatalfc is Fatalf with an explicit exit code, for tools whose callers tell failures
+// apart by exit status
+func (i *ILog) Fatalfc(code int, formattedString string, params ...interface{}) {
+	i.fatal(code, formattedString, params)
+}
+
+// fatalCode returns FatalCode, defaulting to 1
+func (i *ILog) fatalCode() int {
+	if i.FatalCode == 0 {
+		return 1
+	}
+	return i.FatalCode
+}
+
+// fatal logs at LError, syncs and exits with code through ExitFunc
+func (i *ILog) fatal(code int, formattedString string, params []interface{}) {
+	if i.enabled(LError) {
+		// skip entry, fatal and the exported func to reach the caller
+		i.write(i.entry(3, time.Now(), LError, true, formattedString, params))
+	}
+	i.Sync()
+
+	if i.ExitFunc != nil {
+		i.ExitFunc(code)
+		return
+	}
+	os.Exit(code)
+}
+
+// Panic is equivalent to calling Errorf followed by panic(params)
+func (i *ILog) Panic(formattedString string, params ...interface{}) {
+	s := safeSprintf(formattedString, params...)
+	i.log(LError, true, formattedString, params...)
+	panic(s)
+}
+
+// PanicWith writes one structured error entry holding the panic value, the goroutine's
+// stack and any extra fields, then panics with value, so post-mortem tooling always
+// finds the same "panic" and "stack" fields
+func (i *ILog) PanicWith(value interface{}, fields map[string]interface{}) {
+	if i.enabled(LError) {
+		// skip entry and PanicWith to reach the caller
+		e := i.entry(2, time.Now(), LError, true, "%v", []interface{}{value})
+		if e.Fields == nil {
+			e.Fields = make(map[string]interface{}, len(fields)+2)
+		}
+		for k, v := range fields {
+			e.Fields[k] = v
+		}
+		e.Fields["panic"] = safeSprintf("%v", value)
+		e.Fields["stack"] = string(debug.Stack())
+
+		i.write(e)
+	}
+
+	panic(value)
+}
+
+// Error log; an err carrying a Coder adds its code as an "error_code" field
+func (i *ILog) Error(err error) {
+	if !i.enabled(LError) {
+		return
+	}
+
+	// skip entry, logError and Error to reach the caller
+	i.logError(3, err)
+}
+
+// logError logs err without a prefix, adding the code of a Coder; skip is the runtime.Caller
+// depth of the logging call, as for entry
+func (i *ILog) logError(skip int, err error) {
+	e := i.entry(skip, time.Now(), LError, false, err.Error(), nil)
+	if code := errorCode(err); code != "" {
+		e.Fields = withField(e.Fields, "error_code", code)
+	}
+	i.write(e)
+}
+
+// Coder is implemented by errors that carry a classification code, which is logged as an
+// "error_code" field whenever such an error is the trailing param or passed to Error
+type Coder interface {
+	Code() string
+}
+
+// ErrorWithCode logs err like Errorf("%v", err) with code as its "error_code" field,
+// taking precedence over any code err carries itself
+func (i *ILog) ErrorWithCode(code string, err error) {
+	if !i.enabled(LError) {
+		return
+	}
+
+	// skip entry and ErrorWithCode to reach the caller
+	e := i.entry(2, time.Now(), LError, true, "%v", []interface{}{err})
+	e.Fields = withField(e.Fields, "error_code", code)
+	i.write(e)
+}
+
+// errorCode returns the code of the first error in err's chain that is a Coder, or ""
+func errorCode(err error) string {
+	var c Coder
+	if errors.As(err, &c) {
+		return c.Code()
+	}
+	return ""
+}
+
+// Mandatory always logs regardless of logging level
+func (i *ILog) Mandatory(formattedString string, params ...interface{}) {
+	i.log(LMandatory, false, formattedString, params...)
+}
+
+// Errorf log
+func (i *ILog) Errorf(formattedString string, params ...interface{}) {
+	i.log(LError, true, formattedString, params...)
+}
+
+// Warn log
+func (i *ILog) Warn(formattedString string, params ...interface{}) {
+	i.log(LWarn, true, formattedString, params...)
+}
+
+// Info log
+func (i *ILog) Info(formattedString string, params ...interface{}) {
+	i.log(LInfo, true, formattedString, params...)
+}
+
+// Debug log
+func (i *ILog) Debug(formattedString string, params ...interface{}) {
+	i.log(LDebug, true, formattedString, params...)
+}
+
+// logFunc logs the message built by f, calling f only when level is enabled
+func (i *ILog) logFunc(level LogLevel, f func() string) {
+	if !i.enabled(level) {
+		return
+	}
+
+	// skip entry, logFunc and the exported method to reach the caller
+	i.write(i.entry(3, time.Now(), level, true, "%s", []interface{}{f()}))
+}
+
+// ErrorFunc logs like Errorf, calling f for the message only when LError is enabled
+func (i *ILog) ErrorFunc(f func() string) {
+	i.logFunc(LError, f)
+}
+
+// WarnFunc logs like Warn, calling f for the message only when LWarn is enabled
+func (i *ILog) WarnFunc(f func() string) {
+	i.logFunc(LWarn, f)
+}
+
+// InfoFunc logs like Info, calling f for the message only when LInfo is enabled
+func (i *ILog) InfoFunc(f func() string) {
+	i.logFunc(LInfo, f)
+}
+
+// DebugFunc logs like Debug, calling f for the message only when LDebug is enabled, so
+// expensive messages cost nothing while debug output is off
+func (i *ILog) DebugFunc(f func() string) {
+	i.logFunc(LDebug, f)
 }
@@ -0,0 +1,1753 @@
+package ilogger
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// setenv sets key for the rest of the test and reloads the package config, restoring
+// both when the test ends
+func setenv(tb testing.TB, key, value string) {
+	tb.Helper()
+
+	old, had := os.LookupEnv(key)
+	os.Setenv(key, value)
+	ResetForTest()
+
+	tb.Cleanup(func() {
+		if had {
+			os.Setenv(key, old)
+		} else {
+			os.Unsetenv(key)
+		}
+		ResetForTest()
+	})
+}
+
+// newTestLogger finishes i with FromStruct in a temporary directory, at LDebug unless a
+// Level is set, and closes it when the test ends
+func newTestLogger(tb testing.TB, i *ILog) *ILog {
+	tb.Helper()
+
+	if i.Path == "" {
+		i.Path = tb.TempDir()
+	}
+	if i.Level == 0 {
+		i.Level = LDebug
+	}
+	l, err := FromStruct(i)
+	if err != nil {
+		tb.Fatalf("FromStruct: %v", err)
+	}
+	tb.Cleanup(func() { l.Close() })
+
+	return l
+}
+
+// readLog syncs l and returns the contents of its current file
+func readLog(tb testing.TB, l *ILog) string {
+	tb.Helper()
+
+	if err := l.Sync(); err != nil {
+		tb.Fatalf("Sync: %v", err)
+	}
+
+	l.mu.RLock()
+	name := l.logFile.Name()
+	l.mu.RUnlock()
+
+	return readFile(tb, name)
+}
+
+// readFile returns the contents of name
+func readFile(tb testing.TB, name string) string {
+	tb.Helper()
+
+	b, err := ioutil.ReadFile(name)
+	if err != nil {
+		tb.Fatalf("ReadFile: %v", err)
+	}
+	return string(b)
+}
+
+// lines splits s into its non-empty lines
+func lines(s string) []string {
+	var out []string
+	for _, line := range strings.Split(s, "\n") {
+		if line != "" {
+			out = append(out, line)
+		}
+	}
+	return out
+}
+
+// stringerFunc is a fmt.Stringer backed by a func, for observing when params are formatted
+type stringerFunc func() string
+
+func (f stringerFunc) String() string {
+	return f()
+}
+
+func TestDisableWritesNothing(t *testing.T) {
+	setenv(t, disableEnv, "true")
+	l := newTestLogger(t, &ILog{})
+
+	formatted := false
+	l.Mandatory("mandatory")
+	l.Errorf("error %v", stringerFunc(func() string { formatted = true; return "x" }))
+	l.DebugFunc(func() string { formatted = true; return "debug" })
+
+	if got := readLog(t, l); got != "" {
+		t.Errorf("disabled logger wrote %q", got)
+	}
+	if formatted {
+		t.Error("disabled logger formatted its params")
+	}
+}
+
+func BenchmarkDisabled(b *testing.B) {
+	setenv(b, disableEnv, "true")
+	l := newTestLogger(b, &ILog{})
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		l.Info("request %d handled", n)
+	}
+}
+
+func BenchmarkEnabled(b *testing.B) {
+	l := newTestLogger(b, &ILog{})
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		l.Info("request %d handled", n)
+	}
+}
+
+func TestTrailingErrorChain(t *testing.T) {
+	l := newTestLogger(t, &ILog{ErrorType: true})
+
+	err := fmt.Errorf("load config: %w", os.ErrNotExist)
+	l.Info("startup failed: %v", err)
+
+	got := readLog(t, l)
+	if !strings.Contains(got, "INFO - startup failed: load config: file does not exist (*fmt.wrapError)") {
+		t.Errorf("error chain and type missing from %q", got)
+	}
+}
+
+func TestRotationBoundaries(t *testing.T) {
+	at := func(s string) time.Time {
+		tm, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tm
+	}
+
+	tests := []struct {
+		rotation      RotationInterval
+		before, after string
+		suffix        string
+	}{
+		// Sunday to Monday starts ISO week 2
+		{RotateWeekly, "2023-01-08T23:59:59Z", "2023-01-09T00:00:00Z", "2023_W02"},
+		// ISO week 1 of 2021 starts on Monday January 4
+		{RotateWeekly, "2021-01-03T23:59:59Z", "2021-01-04T00:00:00Z", "2021_W01"},
+		{RotateMonthly, "2023-01-31T23:59:59Z", "2023-02-01T00:00:00Z", "2023_02"},
+		{RotateMonthly, "2023-12-31T23:59:59Z", "2024-01-01T00:00:00Z", "2024_01"},
+		{RotateDaily, "2023-02-28T23:59:59Z", "2023-03-01T00:00:00Z", "2023_03_01"},
+	}
+	for _, tt := range tests {
+		before, after := at(tt.before), at(tt.after)
+		if tt.rotation.key(before) == tt.rotation.key(after) {
+			t.Errorf("%v: %s and %s share a period", tt.rotation, tt.before, tt.after)
+		}
+		if got := tt.rotation.suffix(after); got != tt.suffix {
+			t.Errorf("%v: suffix(%s) = %q, want %q", tt.rotation, tt.after, got, tt.suffix)
+		}
+
+		l := &ILog{Path: "logs", Rotation: tt.rotation}
+		if l.fileName(before, 0) == l.fileName(after, 0) {
+			t.Errorf("%v: %s and %s share a file", tt.rotation, tt.before, tt.after)
+		}
+	}
+
+	// the same week or month keeps its file
+	if RotateWeekly.key(at("2023-01-09T00:00:00Z")) != RotateWeekly.key(at("2023-01-15T23:59:59Z")) {
+		t.Error("weekly rotation split an ISO week")
+	}
+	if RotateMonthly.key(at("2023-02-01T00:00:00Z")) != RotateMonthly.key(at("2023-02-28T23:59:59Z")) {
+		t.Error("monthly rotation split a month")
+	}
+}
+
+func TestComponentOnEveryLine(t *testing.T) {
+	l := newTestLogger(t, &ILog{Component: "billing"})
+	l.Info("charged")
+	l.Warn("retrying")
+	l.Log(LError, "declined")
+
+	got := lines(readLog(t, l))
+	if len(got) != 3 {
+		t.Fatalf("got %d lines, want 3: %q", len(got), got)
+	}
+	for _, line := range got {
+		if !strings.Contains(line, " billing: ") {
+			t.Errorf("component missing from %q", line)
+		}
+	}
+
+	j := newTestLogger(t, &ILog{Component: "billing", Encoder: JSONEncoder{}})
+	j.Info("charged")
+
+	var e map[string]interface{}
+	if err := json.Unmarshal([]byte(readLog(t, j)), &e); err != nil {
+		t.Fatal(err)
+	}
+	if e["component"] != "billing" || e["msg"] != "charged" {
+		t.Errorf("JSON entry = %v, want component billing and msg charged", e)
+	}
+}
+
+// expireFile makes l's open file look like it belongs to the previous period, as if the
+// clock had just crossed a rotation boundary
+func expireFile(l *ILog) {
+	l.mu.Lock()
+	l.fileKey--
+	l.mu.Unlock()
+}
+
+func TestRotationOncePerBoundary(t *testing.T) {
+	l := newTestLogger(t, &ILog{})
+
+	var rotations int32
+	l.OnRotate = func(string) { atomic.AddInt32(&rotations, 1) }
+	expireFile(l)
+
+	const writers = 64
+	start := make(chan struct{})
+	var wg sync.WaitGroup
+	for n := 0; n < writers; n++ {
+		wg.Add(1)
+		go func(n int) {
+			defer wg.Done()
+			<-start
+			l.Info("writer %d", n)
+		}(n)
+	}
+	close(start)
+	wg.Wait()
+
+	if got := atomic.LoadInt32(&rotations); got != 1 {
+		t.Errorf("OnRotate fired %d times at one boundary, want 1", got)
+	}
+	if got := len(lines(readLog(t, l))); got != writers {
+		t.Errorf("got %d lines, want %d", got, writers)
+	}
+}
+
+func TestOnRotateMayLog(t *testing.T) {
+	l := newTestLogger(t, &ILog{})
+	l.OnRotate = func(name string) { l.Info("rotated to %s", filepath.Base(name)) }
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		expireFile(l)
+		l.Info("after boundary")
+		if err := l.Rotate(); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("OnRotate logging deadlocked")
+	}
+
+	if got := readLog(t, l); !strings.Contains(got, "INFO - rotated to ") {
+		t.Errorf("OnRotate entry missing from %q", got)
+	}
+}
+
+// colorConfig points LOG_COLOR_CONFIG at a file holding yaml for the rest of the test
+func colorConfig(tb testing.TB, yaml string) {
+	tb.Helper()
+
+	name := filepath.Join(tb.TempDir(), "colors.yaml")
+	if err := ioutil.WriteFile(name, []byte(yaml), 0644); err != nil {
+		tb.Fatal(err)
+	}
+	setenv(tb, colorConfigEnv, name)
+}
+
+func TestRegisterLevel(t *testing.T) {
+	colorConfig(t, "- level: AUDIT\n  color: MAGENTA\n")
+	const audit = LogLevel(6) // between LWarn and LInfo
+	RegisterLevel("audit", audit)
+
+	l := newTestLogger(t, &ILog{Color: ColorAlways, Flags: log.Lmsgprefix})
+	l.SetLogLevel("AUDIT")
+	if l.Level != audit {
+		t.Fatalf("SetLogLevel(AUDIT) set %v, want %v", l.Level, audit)
+	}
+	if got := audit.String(); got != "AUDIT" {
+		t.Errorf("String() = %q, want AUDIT", got)
+	}
+
+	l.Logf(audit, "user %s exported data", "ann")
+	l.Warn("kept")
+	l.Info("filtered")
+
+	got := lines(readLog(t, l))
+	want := []string{
+		colorCodes[magentaEnum] + "AUDIT - user ann exported data" + colorReset,
+		"WARN - kept",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+// fakeClock is a settable time source for the logger's rotation decisions
+type fakeClock struct {
+	mu sync.Mutex
+	t  time.Time
+}
+
+func newFakeClock(s string) *fakeClock {
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		panic(err)
+	}
+	return &fakeClock{t: t}
+}
+
+func (c *fakeClock) now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.t
+}
+
+func (c *fakeClock) add(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.t = c.t.Add(d)
+}
+
+// exists reports whether name is present
+func exists(name string) bool {
+	_, err := os.Stat(name)
+	return err == nil
+}
+
+func TestRotateOnSize(t *testing.T) {
+	clock := newFakeClock("2023-05-10T12:00:00Z")
+	l := newTestLogger(t, &ILog{clock: clock.now, MaxBytes: 100, Flags: log.Lmsgprefix})
+
+	for n := 0; n < 10; n++ {
+		l.Info("entry %d of a message long enough to fill files", n)
+	}
+
+	day := clock.now()
+	for seq := 0; seq < 4; seq++ {
+		if !exists(l.fileName(day, seq)) {
+			t.Fatalf("sequence file %d missing", seq)
+		}
+		if info, _ := os.Stat(l.fileName(day, seq)); info.Size() < l.MaxBytes {
+			t.Errorf("sequence file %d rotated at %d bytes, under MaxBytes", seq, info.Size())
+		}
+	}
+}
+
+func TestRotateOnPeriod(t *testing.T) {
+	clock := newFakeClock("2023-05-10T23:59:59Z")
+	l := newTestLogger(t, &ILog{clock: clock.now, Flags: log.Lmsgprefix})
+
+	first := clock.now()
+	l.Info("before midnight")
+	clock.add(time.Second)
+	l.Info("after midnight")
+
+	if got := readFile(t, l.fileName(first, 0)); got != "INFO - before midnight\n" {
+		t.Errorf("first day's file = %q", got)
+	}
+	if got := readLog(t, l); got != "INFO - after midnight\n" {
+		t.Errorf("second day's file = %q", got)
+	}
+	if l.logFile.Name() != l.fileName(clock.now(), 0) {
+		t.Errorf("writing to %s, want the new day's first file", l.logFile.Name())
+	}
+}
+
+func TestRotateOnSizeThenPeriod(t *testing.T) {
+	clock := newFakeClock("2023-05-10T23:59:00Z")
+	l := newTestLogger(t, &ILog{clock: clock.now, MaxBytes: 40, Flags: log.Lmsgprefix})
+
+	for n := 0; n < 3; n++ {
+		l.Info("entry %d, long enough to fill a file", n)
+	}
+	if !exists(l.fileName(clock.now(), 2)) {
+		t.Fatal("size rotation did not reach sequence 2")
+	}
+
+	// the sequence starts over with the new period
+	clock.add(time.Minute)
+	l.Info("next day")
+	if want := l.fileName(clock.now(), 0); l.logFile.Name() != want {
+		t.Errorf("writing to %s, want %s", l.logFile.Name(), want)
+	}
+}
+
+func TestHeaderOncePerFile(t *testing.T) {
+	clock := newFakeClock("2023-05-10T23:59:59Z")
+	dir := t.TempDir()
+	l := newTestLogger(t, &ILog{Path: dir, clock: clock.now, Header: true})
+	l.Info("first")
+	clock.add(time.Second)
+	l.Info("rotated")
+
+	got := lines(readLog(t, l))
+	if len(got) != 2 {
+		t.Fatalf("rotated file has %d lines, want header and entry: %q", len(got), got)
+	}
+	var h logHeader
+	if err := json.Unmarshal([]byte(got[0]), &h); err != nil {
+		t.Fatalf("first line %q is not a header: %v", got[0], err)
+	}
+	if h.Format != "text" || h.Version != headerVersion || !h.Start.Equal(clock.now()) {
+		t.Errorf("header = %+v", h)
+	}
+
+	// reopening the same file appends without a second header
+	l.Close()
+	again := newTestLogger(t, &ILog{Path: dir, clock: clock.now, Header: true})
+	again.Info("appended")
+	if got := readLog(t, again); strings.Count(got, `"format"`) != 1 {
+		t.Errorf("header repeated on append: %q", got)
+	}
+}
+
+func TestSequenceAcrossRotation(t *testing.T) {
+	clock := newFakeClock("2023-05-10T23:59:59Z")
+	l := newTestLogger(t, &ILog{clock: clock.now, Level: LInfo, Sequence: true, Flags: log.Lmsgprefix})
+
+	first := clock.now()
+	l.Info("one")
+	l.Debug("filtered")
+	l.Warn("two")
+	clock.add(time.Second)
+	l.Errorf("three")
+
+	if got, want := readFile(t, l.fileName(first, 0)), "[1] INFO - one\n[2] WARN - two\n"; got != want {
+		t.Errorf("first file = %q, want %q", got, want)
+	}
+	if got, want := readLog(t, l), "[3] ERROR - three\n"; got != want {
+		t.Errorf("rotated file = %q, want %q", got, want)
+	}
+}
+
+func TestOnLogCounts(t *testing.T) {
+	l := newTestLogger(t, &ILog{Level: LWarn})
+
+	var mu sync.Mutex
+	counts := map[LogLevel]int{}
+	var last string
+	l.OnLog = func(level LogLevel, msg string) {
+		mu.Lock()
+		defer mu.Unlock()
+		counts[level]++
+		last = msg
+	}
+
+	l.Errorf("one")
+	l.Warn("two")
+	l.Warn("three %d", 3)
+	l.Info("filtered")
+	l.Debug("filtered")
+
+	want := map[LogLevel]int{LError: 1, LWarn: 2}
+	if !reflect.DeepEqual(counts, want) {
+		t.Errorf("OnLog counts = %v, want %v", counts, want)
+	}
+	if last != "three 3" {
+		t.Errorf("last message = %q, want %q", last, "three 3")
+	}
+}
+
+func TestOnLogPanicRecovered(t *testing.T) {
+	l := newTestLogger(t, &ILog{})
+	l.OnLog = func(LogLevel, string) { panic("boom") }
+
+	l.Info("survives")
+	if got := readLog(t, l); !strings.Contains(got, "survives") {
+		t.Errorf("entry missing after hook panic: %q", got)
+	}
+}
+
+func TestOnLogMayLog(t *testing.T) {
+	l := newTestLogger(t, &ILog{Sequence: true, Flags: log.Lmsgprefix})
+	l.OnLog = func(level LogLevel, msg string) {
+		if level != LDebug {
+			l.Debug("saw %s", msg)
+		}
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		l.Info("hello")
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("OnLog logging deadlocked")
+	}
+
+	if got, want := readLog(t, l), "[1] INFO - hello\n[2] DEBUG - saw hello\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestFatalfAndPanicUseErrorPrefix(t *testing.T) {
+	colorConfig(t, "- level: ERROR\n  color: RED\n")
+	code := 0
+	l := newTestLogger(t, &ILog{Color: ColorAlways, Flags: log.Lmsgprefix, ExitFunc: func(c int) { code = c }})
+
+	l.Fatalf("cannot start: %s", "port in use")
+	if code != 1 {
+		t.Errorf("exit code = %d, want 1", code)
+	}
+
+	func() {
+		defer func() {
+			if r := recover(); r != "bad state 7" {
+				t.Errorf("recovered %v, want the formatted message", r)
+			}
+		}()
+		l.Panic("bad state %d", 7)
+	}()
+
+	red := colorCodes[redEnum]
+	want := red + "ERROR - cannot start: port in use" + colorReset + "\n" + red + "ERROR - bad state 7" + colorReset + "\n"
+	if got := readLog(t, l); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestFilteredCallsDoNotAllocate(t *testing.T) {
+	l := newTestLogger(t, &ILog{Level: LWarn})
+
+	allocs := testing.AllocsPerRun(100, func() {
+		l.Info("cache warmed")
+		l.Debug("cache warmed")
+	})
+	if allocs != 0 {
+		t.Errorf("filtered calls allocated %v times, want 0", allocs)
+	}
+}
+
+func BenchmarkInfoFiltered(b *testing.B) {
+	l := newTestLogger(b, &ILog{Level: LWarn})
+
+	b.ReportAllocs()
+	for n := 0; n < b.N; n++ {
+		l.Info("cache warmed")
+	}
+}
+
+func TestResetForTestIsolatesEnv(t *testing.T) {
+	t.Run("debug", func(t *testing.T) {
+		setenv(t, logLevelEnv, "DEBUG")
+		colorConfig(t, "- level: INFO\n  color: GREEN\n")
+		RegisterLevel("AUDIT", 6)
+
+		l, err := FromStruct(&ILog{Path: t.TempDir()})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer l.Close()
+		if l.Level != LDebug || !showColors {
+			t.Errorf("level %v, colors %v; want DEBUG with colors", l.Level, showColors)
+		}
+	})
+
+	t.Run("default", func(t *testing.T) {
+		l, err := FromStruct(&ILog{Path: t.TempDir()})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer l.Close()
+		if l.Level != LError {
+			t.Errorf("level %v leaked from the previous test, want ERROR", l.Level)
+		}
+		if showColors || len(colorMap) != 0 {
+			t.Error("colors leaked from the previous test")
+		}
+		if customLevel("AUDIT") != 0 {
+			t.Error("registered level leaked from the previous test")
+		}
+	})
+}
+
+func TestDefaultFlagsMatchFileDate(t *testing.T) {
+	clock := newFakeClock("2023-05-09T17:00:00Z")
+	l := newTestLogger(t, &ILog{clock: clock.now})
+	if l.Flags != DefaultFlags {
+		t.Fatalf("Flags = %d, want DefaultFlags", l.Flags)
+	}
+
+	// the same instant on a clock ten hours ahead is already the next day locally
+	sydney := time.FixedZone("AEST", 10*60*60)
+	l.LogAt(clock.now().In(sydney), LInfo, "deployed")
+
+	if !strings.HasSuffix(l.logFile.Name(), "_2023_05_09.log") {
+		t.Fatalf("file %s is not named for the UTC date", l.logFile.Name())
+	}
+	if got := readLog(t, l); !strings.HasPrefix(got, "2023/05/09 17:00:00.000000 INFO - deployed") {
+		t.Errorf("line %q is not stamped in UTC", got)
+	}
+}
+
+func TestLocalFlags(t *testing.T) {
+	l := newTestLogger(t, &ILog{Flags: log.LstdFlags})
+
+	sydney := time.FixedZone("AEST", 10*60*60)
+	at := time.Date(2023, 5, 10, 3, 0, 0, 0, sydney)
+	l.LogAt(at, LInfo, "local")
+
+	want := at.Local().Format("2006/01/02 15:04:05") + " INFO - local\n"
+	if got := readLog(t, l); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestLogOnce(t *testing.T) {
+	l := newTestLogger(t, &ILog{Level: LWarn, Flags: log.Lmsgprefix})
+
+	for n := 0; n < 3; n++ {
+		l.LogOnce("v1-api", LWarn, "v1 API is deprecated")
+		l.LogOnce("old-flag", LWarn, "--old-flag is deprecated")
+		// filtered calls don't use up their key
+		l.LogOnce("debug-key", LDebug, "debug once")
+	}
+	l.Level = LDebug
+	l.LogOnce("debug-key", LDebug, "debug once")
+	l.LogOnce("debug-key", LDebug, "debug once")
+
+	want := "WARN - v1 API is deprecated\nWARN - --old-flag is deprecated\nDEBUG - debug once\n"
+	if got := readLog(t, l); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestFlagsSurviveRotation(t *testing.T) {
+	clock := newFakeClock("2023-05-10T23:59:59Z")
+	l := newTestLogger(t, &ILog{clock: clock.now, Flags: log.Lshortfile | log.Lmsgprefix, Prefix: "[api] "})
+	line := regexp.MustCompile(`^ilog_test\.go:\d+: \[api\] INFO - (\w+)\n$`)
+
+	l.Info("before")
+	clock.add(time.Second)
+	l.Info("after")
+	if got := readLog(t, l); !line.MatchString(got) {
+		t.Errorf("line after period rotation lost the flags or prefix: %q", got)
+	}
+
+	if err := l.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	l.Info("manual")
+	if got := readLog(t, l); !line.MatchString(got) {
+		t.Errorf("line after Rotate lost the flags or prefix: %q", got)
+	}
+}
+
+func TestGoroutineIDField(t *testing.T) {
+	l := newTestLogger(t, &ILog{Encoder: JSONEncoder{}, GoroutineID: true})
+
+	var wg sync.WaitGroup
+	for n := 0; n < 4; n++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			l.Info("worker")
+		}()
+	}
+	wg.Wait()
+
+	ids := map[float64]bool{}
+	for _, e := range jsonEntries(t, l) {
+		id, ok := e["goroutine"].(float64)
+		if !ok || id == 0 {
+			t.Fatalf("goroutine field = %v", e["goroutine"])
+		}
+		ids[id] = true
+	}
+	if len(ids) != 4 {
+		t.Errorf("4 goroutines logged %d distinct IDs", len(ids))
+	}
+}
+
+func TestConfigLoadsOnFirstUse(t *testing.T) {
+	colorConfig(t, "- level: INFO\n  color: GREEN\n")
+	setenv(t, logLevelEnv, "WARN")
+
+	// forget the loaded config, as if the package had just been imported
+	levelMu.Lock()
+	colorMap = map[LogLevel]int{}
+	showColors = false
+	levelMu.Unlock()
+	logLevelConfig = ""
+	configOnce = sync.Once{}
+
+	l := &ILog{Path: t.TempDir(), Flags: log.Lmsgprefix}
+	if showColors || logLevelConfig != "" {
+		t.Fatal("constructing a logger read the config")
+	}
+
+	if err := l.NewFile(l.Path, 0, -1); err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	if !showColors || l.Level != LWarn {
+		t.Errorf("first use left colors %v and level %v, want the env config", showColors, l.Level)
+	}
+}
+
+func TestEnableLevels(t *testing.T) {
+	l := newTestLogger(t, &ILog{Level: LInfo, Flags: log.Lmsgprefix})
+	l.EnableLevels(LError | LDebug)
+
+	l.Mandatory("always")
+	l.Errorf("kept")
+	l.Warn("dropped")
+	l.Info("dropped")
+	l.Debug("kept")
+
+	if got, want := readLog(t, l), "always\nERROR - kept\nDEBUG - kept\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	// clearing the mask goes back to the threshold
+	l.EnableLevels(0)
+	l.Info("threshold")
+	l.Debug("dropped")
+	if got := readLog(t, l); !strings.HasSuffix(got, "INFO - threshold\n") {
+		t.Errorf("threshold mode not restored: %q", got)
+	}
+}
+
+func TestEnableLevelsCustom(t *testing.T) {
+	t.Cleanup(ResetForTest)
+	const between = LogLevel(6) // LError|LWarn's bits, ordered between them
+	const audit = LogLevel(32)  // a bit of its own
+	RegisterLevel("between", between)
+	RegisterLevel("audit", audit)
+
+	l := newTestLogger(t, &ILog{Flags: log.Lmsgprefix})
+	l.EnableLevels(LError | audit)
+	l.Logf(between, "dropped")
+	l.Logf(audit, "kept")
+	l.Errorf("kept")
+	l.Warn("dropped")
+
+	if got, want := readLog(t, l), "AUDIT - kept\nERROR - kept\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if got, want := l.Config().EnabledLevels, []string{"ERROR", "AUDIT"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("EnabledLevels = %q, want %q", got, want)
+	}
+}
+
+func TestNoCaller(t *testing.T) {
+	for _, noCaller := range []bool{false, true} {
+		l := newTestLogger(t, &ILog{Encoder: JSONEncoder{}, NoCaller: noCaller})
+		l.Info("hello")
+
+		caller, ok := jsonEntries(t, l)[0]["caller"].(string)
+		if noCaller && ok {
+			t.Errorf("NoCaller still wrote caller %q", caller)
+		}
+		if !noCaller && !strings.Contains(caller, "ilog_test.go:") {
+			t.Errorf("caller = %q, want this file", caller)
+		}
+	}
+}
+
+func BenchmarkJSONCaller(b *testing.B) {
+	for _, noCaller := range []bool{false, true} {
+		b.Run(fmt.Sprintf("NoCaller=%v", noCaller), func(b *testing.B) {
+			l := newTestLogger(b, &ILog{Encoder: JSONEncoder{}, NoCaller: noCaller})
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for n := 0; n < b.N; n++ {
+				l.Info("request %d handled", n)
+			}
+		})
+	}
+}
+
+func TestFromStructDefaults(t *testing.T) {
+	dir := t.TempDir()
+	l, err := FromStruct(&ILog{Path: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	if l.Level != LError {
+		t.Errorf("Level = %v, want ERROR from an unset LOG_LEVEL", l.Level)
+	}
+	if l.Flags != DefaultFlags {
+		t.Errorf("Flags = %v, want DefaultFlags", l.Flags)
+	}
+	if l.Rotation != RotateDaily {
+		t.Errorf("Rotation = %v, want daily", l.Rotation)
+	}
+	if _, ok := l.encoder().(TextEncoder); !ok {
+		t.Errorf("encoder = %T, want TextEncoder", l.encoder())
+	}
+	if l.colorEnabled() {
+		t.Error("colors enabled for a file")
+	}
+
+	l.Errorf("written")
+	if !exists(l.fileName(time.Now(), 0)) {
+		t.Errorf("no file for today in %s", dir)
+	}
+	if got := readLog(t, l); !regexp.MustCompile(`^\d{4}/\d\d/\d\d \d\d:\d\d:\d\d\.\d{6} ERROR - written\n$`).MatchString(got) {
+		t.Errorf("unexpected default line %q", got)
+	}
+}
+
+func TestFromStructKeepsSetFields(t *testing.T) {
+	l, err := FromStruct(&ILog{Path: t.TempDir(), Level: LInfo, Flags: log.Lmsgprefix, Rotation: RotateWeekly})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	if l.Level != LInfo || l.Flags != log.Lmsgprefix || l.Rotation != RotateWeekly {
+		t.Errorf("got level %v, flags %v, rotation %v", l.Level, l.Flags, l.Rotation)
+	}
+}
+
+func TestFromStructNeedsPath(t *testing.T) {
+	if _, err := FromStruct(&ILog{Level: LInfo}); err == nil {
+		t.Error("FromStruct without a Path succeeded")
+	}
+}
+
+func TestLogAt(t *testing.T) {
+	at := time.Date(2019, 7, 4, 9, 30, 15, 250000000, time.UTC)
+
+	l := newTestLogger(t, &ILog{Flags: DefaultFlags | log.Lmsgprefix})
+	l.LogAt(at, LWarn, "replayed %d", 1)
+	if got, want := readLog(t, l), "2019/07/04 09:30:15.250000 WARN - replayed 1\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	j := newTestLogger(t, &ILog{Encoder: JSONEncoder{}})
+	j.LogAt(at.In(time.FixedZone("EST", -5*3600)), LInfo, "client event")
+	if got := jsonEntries(t, j)[0]["time"]; got != "2019-07-04T09:30:15.250Z" {
+		t.Errorf("time = %v, want the supplied time", got)
+	}
+}
+
+func TestLogBatchContiguous(t *testing.T) {
+	l := newTestLogger(t, &ILog{Level: LInfo, Flags: log.Lmsgprefix})
+
+	const writers = 8
+	var wg sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			l.LogBatch([]Entry{
+				{Level: LInfo, Message: fmt.Sprintf("batch %d start", w)},
+				{Level: LDebug, Message: "filtered"},
+				{Level: LWarn, Message: fmt.Sprintf("batch %d middle", w), Fields: map[string]interface{}{"n": w}},
+				{Level: LError, Message: fmt.Sprintf("batch %d end", w)},
+			})
+			l.Info("single %d", w)
+		}(w)
+	}
+	wg.Wait()
+
+	got := lines(readLog(t, l))
+	if len(got) != writers*4 {
+		t.Fatalf("got %d lines, want %d", len(got), writers*4)
+	}
+	batches := 0
+	for n, line := range got {
+		var w int
+		if _, err := fmt.Sscanf(line, "INFO - batch %d start", &w); err != nil {
+			continue
+		}
+		batches++
+		want := []string{
+			fmt.Sprintf("WARN - batch %d middle n=%d", w, w),
+			fmt.Sprintf("ERROR - batch %d end", w),
+		}
+		if n+2 >= len(got) || !reflect.DeepEqual(got[n+1:n+3], want) {
+			t.Errorf("batch %d not contiguous: %q", w, got[n:])
+		}
+	}
+	if batches != writers {
+		t.Errorf("found %d batches, want %d", batches, writers)
+	}
+}
+
+func BenchmarkLogBatch(b *testing.B) {
+	entries := []Entry{
+		{Level: LInfo, Message: "step one"},
+		{Level: LInfo, Message: "step two"},
+		{Level: LInfo, Message: "step three"},
+		{Level: LInfo, Message: "step four"},
+	}
+
+	b.Run("PerEntry", func(b *testing.B) {
+		l := newTestLogger(b, &ILog{NoCaller: true})
+		b.ReportAllocs()
+		b.ResetTimer()
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				for _, e := range entries {
+					l.Info(e.Message)
+				}
+			}
+		})
+	})
+
+	b.Run("Batch", func(b *testing.B) {
+		l := newTestLogger(b, &ILog{NoCaller: true})
+		b.ReportAllocs()
+		b.ResetTimer()
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				l.LogBatch(entries)
+			}
+		})
+	})
+}
+
+func TestEscapeControls(t *testing.T) {
+	l := newTestLogger(t, &ILog{Flags: log.Lmsgprefix})
+	l.Info("user said %s", "hi\nERROR - forged\r\x1b[31m\tend")
+	l.InfoFields("field", String("note", "a\nb"))
+
+	want := "INFO - user said hi\\nERROR - forged\\r\\x1b[31m\tend\nINFO - field note=a\\nb\n"
+	if got := readLog(t, l); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	raw := newTestLogger(t, &ILog{Flags: log.Lmsgprefix, AllowControls: true})
+	raw.Info("two\nlines")
+	if got, want := readLog(t, raw), "INFO - two\nlines\n"; got != want {
+		t.Errorf("AllowControls: got %q, want %q", got, want)
+	}
+}
+
+func TestCurrentSymlink(t *testing.T) {
+	clock := newFakeClock("2023-05-10T23:59:59Z")
+	l := newTestLogger(t, &ILog{clock: clock.now, CurrentSymlink: "app.log"})
+	link := filepath.Join(l.Path, "app.log")
+
+	first := l.fileName(clock.now(), 0)
+	target, err := os.Readlink(link)
+	if err != nil {
+		t.Skipf("symlinks unavailable: %v", err)
+	}
+	if target != filepath.Base(first) {
+		t.Errorf("link = %q, want %q", target, filepath.Base(first))
+	}
+
+	clock.add(time.Second)
+	l.Info("next day")
+	second := l.fileName(clock.now(), 0)
+	if target, err := os.Readlink(link); err != nil || target != filepath.Base(second) {
+		t.Errorf("after rotation link = %q (%v), want %q", target, err, filepath.Base(second))
+	}
+	if got := readFile(t, link); !strings.Contains(got, "next day") {
+		t.Errorf("link does not reach the active file: %q", got)
+	}
+}
+
+func TestPanicWith(t *testing.T) {
+	l := newTestLogger(t, &ILog{Encoder: JSONEncoder{}})
+
+	func() {
+		defer func() {
+			if r := recover(); r != "out of stock" {
+				t.Errorf("recovered %v, want the panic value", r)
+			}
+		}()
+		l.PanicWith("out of stock", map[string]interface{}{"order": "A-17"})
+	}()
+
+	entries := jsonEntries(t, l)
+	if len(entries) != 1 {
+		t.Fatalf("got %d entries, want 1", len(entries))
+	}
+	e := entries[0]
+	if e["level"] != "ERROR" || e["msg"] != "out of stock" || e["panic"] != "out of stock" || e["order"] != "A-17" {
+		t.Errorf("unexpected entry %v", e)
+	}
+	if stack, _ := e["stack"].(string); !strings.Contains(stack, "goroutine ") || !strings.Contains(stack, "TestPanicWith") {
+		t.Errorf("stack = %q, want this goroutine's stack", stack)
+	}
+	if caller, _ := e["caller"].(string); !strings.Contains(caller, "ilog_test.go:") {
+		t.Errorf("caller = %q, want this file", caller)
+	}
+}
+
+func TestColorEnabled(t *testing.T) {
+	colorConfig(t, "- level: ERROR\n  color: RED\n")
+
+	tests := []struct {
+		color ColorSetting
+		want  bool
+	}{
+		{ColorAuto, false},
+		{ColorAlways, true},
+		{ColorNever, false},
+	}
+	for _, tt := range tests {
+		l := newTestLogger(t, &ILog{Color: tt.color, Flags: log.Lmsgprefix})
+		if got := l.ColorEnabled(); got != tt.want {
+			t.Errorf("%v: ColorEnabled() = %v for a file, want %v", tt.color, got, tt.want)
+		}
+
+		l.Errorf("failed")
+		if got := strings.Contains(readLog(t, l), "\x1b["); got != tt.want {
+			t.Errorf("%v: escape codes written = %v, want %v", tt.color, got, tt.want)
+		}
+	}
+
+	// the default needs no setting at all
+	if l := newTestLogger(t, &ILog{}); l.ColorEnabled() {
+		t.Error("default Color colors a file")
+	}
+}
+
+func TestWriteBufferFlushedOnClose(t *testing.T) {
+	l := newTestLogger(t, &ILog{Flags: log.Lmsgprefix, WriteBufferBytes: 4096})
+	l.Info("one")
+	l.Warn("two")
+
+	name := l.fileName(time.Now(), 0)
+	if got := readFile(t, name); got != "" {
+		t.Errorf("buffered entries reached the file before Close: %q", got)
+	}
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := readFile(t, name), "INFO - one\nWARN - two\n"; got != want {
+		t.Errorf("after Close got %q, want %q", got, want)
+	}
+}
+
+func TestWriteBufferFlushedOnSync(t *testing.T) {
+	l := newTestLogger(t, &ILog{Flags: log.Lmsgprefix, WriteBufferBytes: 4096})
+	l.Info("synced")
+	if got := readLog(t, l); got != "INFO - synced\n" {
+		t.Errorf("after Sync got %q", got)
+	}
+}
+
+func BenchmarkWriteBuffer(b *testing.B) {
+	for _, size := range []int{0, 64 << 10} {
+		b.Run(fmt.Sprintf("WriteBufferBytes=%d", size), func(b *testing.B) {
+			fs := &countingFS{}
+			l := newTestLogger(b, &ILog{FS: fs, NoCaller: true, WriteBufferBytes: size})
+
+			b.ResetTimer()
+			for n := 0; n < b.N; n++ {
+				l.Info("request %d handled", n)
+			}
+			l.Sync()
+			b.ReportMetric(float64(atomic.LoadInt64(&fs.writes))/float64(b.N), "writes/op")
+		})
+	}
+}
+
+func TestLazyMessageFuncs(t *testing.T) {
+	l := newTestLogger(t, &ILog{Level: LWarn, Flags: log.Lmsgprefix | log.Lshortfile})
+
+	calls := 0
+	msg := func(s string) func() string {
+		return func() string {
+			calls++
+			return s
+		}
+	}
+
+	l.DebugFunc(msg("debug"))
+	l.InfoFunc(msg("info"))
+	if calls != 0 {
+		t.Errorf("filtered levels called the func %d times", calls)
+	}
+
+	l.WarnFunc(msg("warn"))
+	l.ErrorFunc(msg("error"))
+	if calls != 2 {
+		t.Errorf("enabled levels called the func %d times, want 2", calls)
+	}
+
+	got := readLog(t, l)
+	if !regexp.MustCompile(`^ilog_test\.go:\d+: WARN - warn\nilog_test\.go:\d+: ERROR - error\n$`).MatchString(got) {
+		t.Errorf("unexpected output %q", got)
+	}
+}
+
+func TestBytesWritten(t *testing.T) {
+	clock := newFakeClock("2023-05-10T12:00:00Z")
+	l := newTestLogger(t, &ILog{clock: clock.now, Flags: log.Lmsgprefix})
+
+	l.Info("first")
+	l.Warn("second entry")
+	first := uint64(len(readLog(t, l)))
+	if got := l.BytesWritten(); got != first || first == 0 {
+		t.Errorf("BytesWritten() = %d, want %d", got, first)
+	}
+
+	if err := l.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	if got := l.BytesWritten(); got != 0 {
+		t.Errorf("BytesWritten() = %d after Rotate, want 0", got)
+	}
+	l.Errorf("third")
+	second := uint64(len(readLog(t, l)))
+	if got := l.BytesWritten(); got != second {
+		t.Errorf("BytesWritten() = %d, want %d", got, second)
+	}
+	if got := l.BytesToday(); got != first+second {
+		t.Errorf("BytesToday() = %d, want %d across both files", got, first+second)
+	}
+
+	clock.add(12 * time.Hour)
+	if got := l.BytesToday(); got != 0 {
+		t.Errorf("BytesToday() = %d on a new day, want 0", got)
+	}
+	l.Info("tomorrow")
+	if got, want := l.BytesToday(), uint64(len("INFO - tomorrow\n")); got != want {
+		t.Errorf("BytesToday() = %d, want %d", got, want)
+	}
+}
+
+func TestRotate(t *testing.T) {
+	l := newTestLogger(t, &ILog{Flags: log.Lmsgprefix, WriteBufferBytes: 4096})
+	l.Info("before")
+
+	name := l.fileName(time.Now(), 0)
+	if err := l.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	l.Info("after")
+
+	renamed, err := filepath.Glob(strings.TrimSuffix(name, ".log") + ".*.log")
+	if err != nil || len(renamed) != 1 {
+		t.Fatalf("renamed files = %v (%v), want one", renamed, err)
+	}
+	if !regexp.MustCompile(`\.\d{8}T\d{6}\.\d{9}\.log$`).MatchString(renamed[0]) {
+		t.Errorf("renamed file %s lacks a timestamp suffix", renamed[0])
+	}
+	if got := readFile(t, renamed[0]); got != "INFO - before\n" {
+		t.Errorf("renamed file = %q, want the buffered entry", got)
+	}
+	if got := readLog(t, l); got != "INFO - after\n" {
+		t.Errorf("fresh file = %q, want only new entries", got)
+	}
+	if l.logFile.Name() != name {
+		t.Errorf("fresh file is %s, want the base name %s", l.logFile.Name(), name)
+	}
+}
+
+func TestRotateBeforeOpen(t *testing.T) {
+	l := &ILog{Path: t.TempDir(), LazyOpen: true}
+	if err := l.NewFile(l.Path, 0, int(LInfo)); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Rotate(); err != nil {
+		t.Errorf("Rotate with no open file: %v", err)
+	}
+	if names, _ := filepath.Glob(filepath.Join(l.Path, "*")); len(names) != 0 {
+		t.Errorf("Rotate created %v", names)
+	}
+}
+
+func TestOutputEnv(t *testing.T) {
+	t.Run("LOG_FILE", func(t *testing.T) {
+		dir := filepath.Join(t.TempDir(), "var", "log")
+		name := filepath.Join(dir, "app.log")
+		setenv(t, fileEnv, name)
+
+		// the file is used as named, neither dated nor split by MaxBytes
+		l := newTestLogger(t, &ILog{Flags: log.Lmsgprefix, MaxBytes: 10})
+		l.Info("moved")
+		l.Info("not split")
+		if got := readFile(t, name); got != "INFO - moved\nINFO - not split\n" {
+			t.Errorf("LOG_FILE holds %q", got)
+		}
+		if names, _ := filepath.Glob(filepath.Join(dir, "*")); len(names) != 1 {
+			t.Errorf("LOG_FILE's directory holds %v, want only the file", names)
+		}
+
+		// Rotate still moves it aside for a fresh file under the same name
+		if err := l.Rotate(); err != nil {
+			t.Fatal(err)
+		}
+		l.Info("fresh")
+		if got := readFile(t, name); got != "INFO - fresh\n" {
+			t.Errorf("LOG_FILE after Rotate holds %q", got)
+		}
+		if names, _ := filepath.Glob(filepath.Join(dir, "app.*.log")); len(names) != 1 {
+			t.Errorf("rotated files %v, want one", names)
+		}
+	})
+
+	t.Run("file:", func(t *testing.T) {
+		dir := t.TempDir()
+		setenv(t, fileEnv, t.TempDir())
+		setenv(t, outputEnv, "file:"+dir)
+
+		l := newTestLogger(t, &ILog{Flags: log.Lmsgprefix})
+		l.Info("moved")
+		if got := readFile(t, filepath.Join(dir, filepath.Base(l.fileName(time.Now(), 0)))); got != "INFO - moved\n" {
+			t.Errorf("LOG_OUTPUT directory holds %q, want it over LOG_FILE", got)
+		}
+	})
+
+	t.Run("stdout", func(t *testing.T) {
+		r, w, err := os.Pipe()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer r.Close()
+		stdout := os.Stdout
+		os.Stdout = w
+		defer func() { os.Stdout = stdout }()
+		setenv(t, outputEnv, "STDOUT")
+
+		l := newTestLogger(t, &ILog{Flags: log.Lmsgprefix})
+		l.Info("to stdout")
+		w.Close()
+
+		b, _ := ioutil.ReadAll(r)
+		if got := string(b); got != "INFO - to stdout\n" {
+			t.Errorf("stdout got %q", got)
+		}
+		if names, _ := filepath.Glob(filepath.Join(l.Path, "*")); len(names) != 0 {
+			t.Errorf("files created alongside stdout: %v", names)
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		setenv(t, outputEnv, "syslog")
+
+		l := newTestLogger(t, &ILog{Flags: log.Lmsgprefix})
+		l.Info("kept")
+		if got := readFile(t, l.fileName(time.Now(), 0)); got != "INFO - kept\n" {
+			t.Errorf("configured path holds %q", got)
+		}
+	})
+}
+
+// panicValue is a panic value whose Error method panics as well, which fmt can't recover
+type panicValue struct{}
+
+func (panicValue) Error() string {
+	panic("again")
+}
+
+func TestPanickingParams(t *testing.T) {
+	var warnings strings.Builder
+	log.SetOutput(&warnings)
+	defer log.SetOutput(os.Stderr)
+
+	l := newTestLogger(t, &ILog{Flags: log.Lmsgprefix})
+	l.Info("user %v", stringerFunc(func() string { panic("boom") }))
+	l.Info("user %v", stringerFunc(func() string { panic(panicValue{}) }))
+	l.Info("text %s", "%!v(PANIC=String method: not really)")
+	l.Info("still logging")
+
+	want := "INFO - user %v !PANIC\nINFO - user %v !PANIC\nINFO - text %!v(PANIC=String method: not really)\nINFO - still logging\n"
+	if got := readLog(t, l); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if got := strings.Count(warnings.String(), "formatting panicked"); got != 2 {
+		t.Errorf("logged %d warnings, want 2: %q", got, warnings.String())
+	}
+}
+
+func TestSkipEmpty(t *testing.T) {
+	l := newTestLogger(t, &ILog{Flags: log.Lmsgprefix, SkipEmpty: true})
+	l.Info("")
+	l.Info(" \t\n")
+	l.Info("%s", "")
+	l.Warn("kept")
+
+	if got, want := readLog(t, l), "WARN - kept\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	off := newTestLogger(t, &ILog{Flags: log.Lmsgprefix})
+	off.Info("")
+	if got, want := readLog(t, off), "INFO - \n"; got != want {
+		t.Errorf("without SkipEmpty got %q, want %q", got, want)
+	}
+}
+
+func TestOpenRetries(t *testing.T) {
+	eio := errors.New("input/output error")
+
+	t.Run("transient", func(t *testing.T) {
+		fs := newMemFS()
+		fs.mkdirErrs = []error{eio}
+		fs.openErrs = []error{eio}
+		l := &ILog{Path: "/logs", FS: fs, OpenRetries: 2, OpenBackoff: time.Millisecond, Flags: log.Lmsgprefix}
+		if err := l.NewFile(l.Path, 0, int(LInfo)); err != nil {
+			t.Fatalf("NewFile gave up after a transient failure: %v", err)
+		}
+		defer l.Close()
+
+		if fs.mkdirs != 2 || fs.opens != 2 {
+			t.Errorf("made the directory %d times and opened %d times, want 2 each", fs.mkdirs, fs.opens)
+		}
+		l.Info("opened")
+		if got := fs.contents(l.fileName(time.Now(), 0)); got != "INFO - opened\n" {
+			t.Errorf("file holds %q", got)
+		}
+	})
+
+	t.Run("exhausted", func(t *testing.T) {
+		fs := newMemFS()
+		fs.openErrs = []error{eio, eio, eio}
+		l := &ILog{Path: "/logs", FS: fs, OpenRetries: 2, OpenBackoff: time.Millisecond}
+		err := l.NewFile(l.Path, 0, int(LInfo))
+		if !errors.Is(err, eio) {
+			t.Errorf("NewFile = %v, want the open error", err)
+		}
+		if fs.opens != 3 {
+			t.Errorf("opened %d times, want 3", fs.opens)
+		}
+	})
+
+	t.Run("no retries", func(t *testing.T) {
+		fs := newMemFS()
+		fs.openErrs = []error{eio}
+		l := &ILog{Path: "/logs", FS: fs}
+		if err := l.NewFile(l.Path, 0, int(LInfo)); !errors.Is(err, eio) || fs.opens != 1 {
+			t.Errorf("NewFile = %v after %d opens, want the error after one", err, fs.opens)
+		}
+	})
+}
+
+func TestLazyOpen(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "logs")
+	l, err := FromStruct(&ILog{Path: dir, Level: LWarn, LazyOpen: true, Flags: log.Lmsgprefix})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	if exists(dir) {
+		t.Fatal("LazyOpen created the directory before anything was logged")
+	}
+	l.Info("filtered")
+	if exists(dir) {
+		t.Fatal("a filtered entry created the directory")
+	}
+
+	l.Warn("first")
+	if got := readFile(t, l.fileName(time.Now(), 0)); got != "WARN - first\n" {
+		t.Errorf("file holds %q", got)
+	}
+}
+
+func TestBytesFormat(t *testing.T) {
+	payload := []byte("hi!\x00")
+
+	tests := []struct {
+		format BytesFormat
+		msg    string
+		field  interface{}
+	}{
+		{BytesRaw, "payload [104 105 33 0]", "aGkhAA=="}, // encoding/json's own base64
+		{BytesHex, "payload 68692100", "68692100"},
+		{BytesBase64, "payload aGkhAA==", "aGkhAA=="},
+	}
+	for _, tt := range tests {
+		text := newTestLogger(t, &ILog{Flags: log.Lmsgprefix, BytesFormat: tt.format})
+		text.Info("payload %v", payload)
+		if got, want := readLog(t, text), "INFO - "+tt.msg+"\n"; got != want {
+			t.Errorf("%d: text got %q, want %q", tt.format, got, want)
+		}
+
+		js := newTestLogger(t, &ILog{Encoder: JSONEncoder{}, BytesFormat: tt.format})
+		js.InfoFields("payload", Any("body", payload))
+		if got := jsonEntries(t, js)[0]["body"]; got != tt.field {
+			t.Errorf("%d: JSON body = %v, want %v", tt.format, got, tt.field)
+		}
+	}
+
+	if string(payload) != "hi!\x00" {
+		t.Error("rendering changed the caller's slice")
+	}
+}
+
+func TestMaxFilesReusesOldest(t *testing.T) {
+	fs := newMemFS()
+	l := &ILog{Path: "/logs", FS: fs, Flags: log.Lmsgprefix, MaxBytes: 10, MaxFiles: 3}
+	if err := l.NewFile(l.Path, 0, int(LInfo)); err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	for n := 1; n <= 5; n++ {
+		l.Info("entry %d", n)
+		// ModTime orders the ring, so keep each write distinct
+		time.Sleep(time.Millisecond)
+	}
+
+	now := time.Now()
+	want := []string{"INFO - entry 4\n", "INFO - entry 5\n", "INFO - entry 3\n"}
+	for seq, w := range want {
+		if got := fs.contents(l.fileName(now, seq)); got != w {
+			t.Errorf("file %d holds %q, want %q", seq, got, w)
+		}
+	}
+	if len(fs.files) != 3 {
+		t.Errorf("%d files created, want MaxFiles", len(fs.files))
+	}
+}
+
+func TestTags(t *testing.T) {
+	text := newTestLogger(t, &ILog{Flags: log.Lmsgprefix, Tags: []string{"db", "billing"}})
+	text.Info("plain")
+	text.LogTags(LWarn, []string{"slow", "db"}, "query took %dms", 900)
+
+	want := "INFO - plain [db,billing]\nWARN - query took 900ms [db,billing,slow]\n"
+	if got := readLog(t, text); got != want {
+		t.Errorf("text got %q, want %q", got, want)
+	}
+
+	js := newTestLogger(t, &ILog{Encoder: JSONEncoder{}, Tags: []string{"db"}})
+	js.LogTags(LWarn, []string{"slow"}, "query")
+	js.LogBatch([]Entry{{Level: LInfo, Message: "batched", Tags: []string{"db", "bulk"}}})
+
+	entries := jsonEntries(t, js)
+	if got := entries[0]["tags"]; !reflect.DeepEqual(got, []interface{}{"db", "slow"}) {
+		t.Errorf("JSON tags = %v, want [db slow]", got)
+	}
+	if got := entries[1]["tags"]; !reflect.DeepEqual(got, []interface{}{"db", "bulk"}) {
+		t.Errorf("batched tags = %v, want [db bulk]", got)
+	}
+
+	untagged := newTestLogger(t, &ILog{Encoder: JSONEncoder{}})
+	untagged.Info("none")
+	if _, ok := jsonEntries(t, untagged)[0]["tags"]; ok {
+		t.Error("tags written for an untagged entry")
+	}
+}
+
+// unframe splits length-prefixed data back into its frames
+func unframe(tb testing.TB, data []byte) []string {
+	tb.Helper()
+
+	var frames []string
+	for len(data) > 0 {
+		if len(data) < 4 {
+			tb.Fatalf("truncated length prefix %q", data)
+		}
+		n := binary.BigEndian.Uint32(data)
+		data = data[4:]
+		if uint32(len(data)) < n {
+			tb.Fatalf("frame of %d bytes has only %d left", n, len(data))
+		}
+		frames = append(frames, string(data[:n]))
+		data = data[n:]
+	}
+
+	return frames
+}
+
+func TestLengthPrefix(t *testing.T) {
+	l := newTestLogger(t, &ILog{Flags: log.Lmsgprefix, LengthPrefix: true, AllowControls: true, Header: true})
+
+	var out bytes.Buffer
+	defer l.AddScopedOutput(&out)()
+	l.Info("line one\nline two")
+	l.LogBatch([]Entry{{Level: LWarn, Message: "batched"}, {Level: LError, Message: ""}})
+
+	want := []string{"INFO - line one\nline two", "WARN - batched", "ERROR - "}
+	if got := unframe(t, out.Bytes()); !reflect.DeepEqual(got, want) {
+		t.Errorf("output frames = %q, want %q", got, want)
+	}
+
+	file := unframe(t, []byte(readLog(t, l)))
+	if len(file) != 4 {
+		t.Fatalf("file has %d frames, want the header and 3 entries: %q", len(file), file)
+	}
+	var h logHeader
+	if err := json.Unmarshal([]byte(file[0]), &h); err != nil {
+		t.Errorf("header frame %q: %v", file[0], err)
+	}
+	if !reflect.DeepEqual(file[1:], want) {
+		t.Errorf("file frames = %q, want %q", file[1:], want)
+	}
+}
+
+func TestLabels(t *testing.T) {
+	setenv(t, "LOG_LABEL_NAMESPACE", "prod")
+	setenv(t, "LOG_LABEL_POD", "api-7f9")
+
+	l := newTestLogger(t, &ILog{Encoder: JSONEncoder{}})
+	l.Info("env only")
+	l.SetLabels(map[string]string{"version": "1.4.0", "pod": "api-override"})
+	l.Warn("merged")
+	l.LogBatch([]Entry{{Level: LInfo, Message: "batched"}, {Level: LInfo, Message: "own", Labels: map[string]string{"job": "x"}}})
+	l.SetLabels(nil)
+	l.Info("reset")
+
+	envOnly := map[string]interface{}{"namespace": "prod", "pod": "api-7f9"}
+	merged := map[string]interface{}{"namespace": "prod", "pod": "api-override", "version": "1.4.0"}
+	want := []map[string]interface{}{envOnly, merged, merged, {"job": "x"}, envOnly}
+
+	entries := jsonEntries(t, l)
+	if len(entries) != len(want) {
+		t.Fatalf("got %d entries, want %d", len(entries), len(want))
+	}
+	for n, e := range entries {
+		if !reflect.DeepEqual(e["labels"], want[n]) {
+			t.Errorf("entry %q labels = %v, want %v", e["msg"], e["labels"], want[n])
+		}
+	}
+}
+
+func TestVersion(t *testing.T) {
+	l := newTestLogger(t, &ILog{Flags: log.Lmsgprefix, Version: "1.4.0"})
+	l.Info("started")
+	l.InfoFields("charged", String("user", "ann"))
+	if got, want := readLog(t, l), "INFO - started version=1.4.0\nINFO - charged user=ann version=1.4.0\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	setenv(t, "LOG_VERSION", "abc123")
+	fromEnv := newTestLogger(t, &ILog{Encoder: JSONEncoder{}})
+	fromEnv.Info("one")
+	fromEnv.Warn("two")
+	for _, e := range jsonEntries(t, fromEnv) {
+		if e["version"] != "abc123" {
+			t.Errorf("entry %q version = %v, want abc123 from LOG_VERSION", e["msg"], e["version"])
+		}
+	}
+
+	setenv(t, "LOG_VERSION", "")
+	unset := newTestLogger(t, &ILog{Encoder: JSONEncoder{}})
+	unset.Info("one")
+	if _, ok := jsonEntries(t, unset)[0]["version"]; ok {
+		t.Error("version written without Version or LOG_VERSION")
+	}
+}
+
+func TestLevelChangeKeepsBufferedEntries(t *testing.T) {
+	l := newTestLogger(t, &ILog{Flags: log.Lmsgprefix, WriteBufferBytes: 1 << 16})
+
+	l.Debug("buffered under DEBUG")
+	l.Info("buffered info")
+	l.SetLogLevel("ERROR")
+	l.Debug("dropped under ERROR")
+	l.Info("dropped info")
+	l.Errorf("kept error")
+	l.mu.RLock()
+	name := l.logFile.Name()
+	l.mu.RUnlock()
+	if got := readFile(t, name); got != "" {
+		t.Fatalf("file = %q before Sync, want the entries still buffered", got)
+	}
+	l.SetLogLevel("DEBUG")
+	l.Debug("kept after lowering")
+
+	want := "DEBUG - buffered under DEBUG\nINFO - buffered info\nERROR - kept error\nDEBUG - kept after lowering\n"
+	if got := readLog(t, l); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+// codedError is an error carrying a Coder code
+type codedError struct{ code string }
+
+func (e codedError) Error() string { return "failed with " + e.code }
+func (e codedError) Code() string  { return e.code }
+
+func TestErrorCode(t *testing.T) {
+	l := newTestLogger(t, &ILog{Encoder: JSONEncoder{}})
+
+	coded := codedError{code: "E_CARD_DECLINED"}
+	l.Error(coded)
+	l.Error(fmt.Errorf("charge: %w", coded))
+	l.Errorf("retrying after %v", coded)
+	l.ErrorWithCode("E_TIMEOUT", errors.New("gateway timed out"))
+	l.ErrorWithCode("E_OVERRIDE", coded)
+	l.Error(errors.New("no code"))
+
+	want := []struct{ msg, code string }{
+		{"failed with E_CARD_DECLINED", "E_CARD_DECLINED"},
+		{"charge: failed with E_CARD_DECLINED", "E_CARD_DECLINED"},
+		{"retrying after failed with E_CARD_DECLINED", "E_CARD_DECLINED"},
+		{"gateway timed out", "E_TIMEOUT"},
+		{"failed with E_CARD_DECLINED", "E_OVERRIDE"},
+		{"no code", ""},
+	}
+	entries := jsonEntries(t, l)
+	if len(entries) != len(want) {
+		t.Fatalf("got %d entries, want %d", len(entries), len(want))
+	}
+	for n, e := range entries {
+		code, _ := e["error_code"].(string)
+		if e["msg"] != want[n].msg || code != want[n].code {
+			t.Errorf("entry %d = %q with error_code %q, want %q with %q", n, e["msg"], code, want[n].msg, want[n].code)
+		}
+	}
+}
+
+func TestEmptyColorConfig(t *testing.T) {
+	for _, yaml := range []string{"[]\n", "", "# no colors yet\n"} {
+		r, w, err := os.Pipe()
+		if err != nil {
+			t.Fatal(err)
+		}
+		stdout := os.Stdout
+		os.Stdout = w
+		// colorConfig reloads the package config, which reports the empty list
+		colorConfig(t, yaml)
+		os.Stdout = stdout
+		w.Close()
+		diag, _ := ioutil.ReadAll(r)
+		r.Close()
+
+		if !strings.Contains(string(diag), "lists no colors, colors disabled") {
+			t.Errorf("config %q: diagnostic = %q, want a note that colors are disabled", yaml, diag)
+		}
+
+		l := newTestLogger(t, &ILog{Flags: log.Lmsgprefix, Color: ColorAlways})
+		if l.ColorEnabled() {
+			t.Errorf("config %q: ColorEnabled() = true with no colors configured", yaml)
+		}
+		l.Errorf("plain")
+		if got := readLog(t, l); got != "ERROR - plain\n" {
+			t.Errorf("config %q: got %q, want no escape codes", yaml, got)
+		}
+	}
+}
+
+func TestLogWithCaller(t *testing.T) {
+	l := newTestLogger(t, &ILog{})
+	l.LogWithCaller(LWarn, "/src/app/pay.go", 42, "charge took %dms", 900)
+	if got := readLog(t, l); !regexp.MustCompile(`^\S+ \S+ /src/app/pay\.go:42: WARN - charge took 900ms\n$`).MatchString(got) {
+		t.Errorf("default flags got %q, want the supplied location", got)
+	}
+
+	short := newTestLogger(t, &ILog{Flags: log.Lmsgprefix | log.Lshortfile})
+	short.LogWithCaller(LInfo, "/src/app/pay.go", 7, "ok")
+	short.Info("looked up")
+	want := regexp.MustCompile(`^pay\.go:7: INFO - ok\nilog_test\.go:\d+: INFO - looked up\n$`)
+	if got := readLog(t, short); !want.MatchString(got) {
+		t.Errorf("Lshortfile got %q, want %s", got, want)
+	}
+
+	js := newTestLogger(t, &ILog{Encoder: JSONEncoder{}, NoCaller: true})
+	js.LogWithCaller(LError, "vendor/lib.go", 3, "bridged")
+	js.Errorf("not looked up")
+	entries := jsonEntries(t, js)
+	if got := entries[0]["caller"]; got != "vendor/lib.go:3" {
+		t.Errorf("JSON caller = %v, want vendor/lib.go:3", got)
+	}
+	if _, ok := entries[1]["caller"]; ok {
+		t.Error("NoCaller still looked up the caller of a plain entry")
+	}
+}
+
+func TestFatalCodes(t *testing.T) {
+	var codes []int
+	var synced []string
+	l := newTestLogger(t, &ILog{Flags: log.Lmsgprefix, WriteBufferBytes: 1 << 16})
+	l.ExitFunc = func(code int) {
+		codes = append(codes, code)
+		// os.Exit skips deferred Close calls, so the entry must be on disk already
+		l.mu.RLock()
+		name := l.logFile.Name()
+		l.mu.RUnlock()
+		synced = append(synced, readFile(t, name))
+	}
+
+	l.Fatalf("default %d", 1)
+	l.FatalCode = 3
+	l.Fatalf("configured")
+	l.Fatalfc(64, "usage: %s", "tool <file>")
+
+	if want := []int{1, 3, 64}; !reflect.DeepEqual(codes, want) {
+		t.Errorf("exit codes = %v, want %v", codes, want)
+	}
+	want := "ERROR - default 1\nERROR - configured\nERROR - usage: tool <file>\n"
+	if synced[2] != want {
+		t.Errorf("file at exit = %q, want %q", synced[2], want)
+	}
+
+	quiet := newTestLogger(t, &ILog{Flags: log.Lmsgprefix, Level: LMandatory, FatalCode: 2})
+	quiet.ExitFunc = func(code int) { codes = append(codes, code) }
+	quiet.Fatalf("below the level")
+	if got := codes[len(codes)-1]; got != 2 {
+		t.Errorf("exit code below the level = %d, want 2", got)
+	}
+	if got := readLog(t, quiet); got != "" {
+		t.Errorf("logged %q below the level", got)
+	}
+}
+
+func TestRotateWithColorsConcurrently(t *testing.T) {
+	colorConfig(t, "- level: INFO\n  color: GREEN\n")
+	l := newTestLogger(t, &ILog{Flags: log.Lmsgprefix | log.Lshortfile, MaxBytes: 256})
+
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for n := 0; n < 200; n++ {
+				l.InfoFields("rotating", Int("goroutine", g), Int("n", n))
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	// the files aren't terminals, so auto color leaves the lines plain
+	if got := readLog(t, l); strings.Contains(got, "\x1b[") {
+		t.Errorf("escape codes written to a file: %q", got)
+	}
+	if names, _ := filepath.Glob(filepath.Join(l.Path, "*")); len(names) < 2 {
+		t.Errorf("got %d files, want MaxBytes to have rotated", len(names))
+	}
+}
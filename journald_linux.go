@@ -0,0 +1,144 @@
+package ilogger
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// journalSocket is where journald listens for the native protocol
+const journalSocket = "/run/systemd/journal/socket"
+
+// journalFieldPrefix is put in front of field keys that would otherwise clash with the
+// sink's own journal fields or start with a digit
+const journalFieldPrefix = "FIELD_"
+
+// maxJournalFieldName is the longest field name journald accepts
+const maxJournalFieldName = 64
+
+// journalOwnFields are the journal fields WriteEntry sets itself
+var journalOwnFields = map[string]bool{
+	"MESSAGE":           true,
+	"PRIORITY":          true,
+	"SYSLOG_IDENTIFIER": true,
+	"CODE_FILE":         true,
+	"CODE_LINE":         true,
+	"COMPONENT":         true,
+}
+
+// JournalSink sends entries to the systemd journal using its native datagram protocol
+type JournalSink struct {
+	// Identifier is sent as SYSLOG_IDENTIFIER when set
+	Identifier string
+
+	conn *net.UnixConn
+}
+
+// NewJournalSink connects to the local journald socket and fails when it isn't reachable
+func NewJournalSink(identifier string) (*JournalSink, error) {
+	return NewJournalSinkAt(journalSocket, identifier)
+}
+
+// NewJournalSinkAt connects to a journald native protocol socket at path, e.g. a
+// container's mounted journal socket
+func NewJournalSinkAt(path, identifier string) (*JournalSink, error) {
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		return nil, fmt.Errorf("journald not reachable (%s): %w", path, err)
+	}
+
+	return &JournalSink{Identifier: identifier, conn: conn}, nil
+}
+
+// WriteEntry implements Sink; entries too large for one datagram are rejected by the socket
+func (j *JournalSink) WriteEntry(e Entry) error {
+	var b bytes.Buffer
+
+	writeJournalField(&b, "MESSAGE", e.Message)
+	writeJournalField(&b, "PRIORITY", strconv.Itoa(journalPriority(e.Level)))
+	if j.Identifier != "" {
+		writeJournalField(&b, "SYSLOG_IDENTIFIER", j.Identifier)
+	}
+	if n := strings.LastIndexByte(e.Caller, ':'); n > 0 {
+		writeJournalField(&b, "CODE_FILE", e.Caller[:n])
+		writeJournalField(&b, "CODE_LINE", e.Caller[n+1:])
+	}
+	if e.Component != "" {
+		writeJournalField(&b, "COMPONENT", e.Component)
+	}
+	for _, k := range sortedKeys(e.Fields) {
+		if name := journalFieldName(k); name != "" {
+			writeJournalField(&b, name, fmt.Sprint(e.Fields[k]))
+		}
+	}
+
+	_, err := j.conn.Write(b.Bytes())
+	return err
+}
+
+// Close closes the connection to journald
+func (j *JournalSink) Close() error {
+	return j.conn.Close()
+}
+
+// journalPriority maps a level to a syslog priority; custom levels use the nearest standard
+// level at or above their threshold
+func journalPriority(level LogLevel) int {
+	switch {
+	case level == LMandatory:
+		return 5 // notice
+	case level <= LError:
+		return 3 // err
+	case level <= LWarn:
+		return 4 // warning
+	case level <= LInfo:
+		return 6 // info
+	default:
+		return 7 // debug
+	}
+}
+
+// writeJournalField writes one field, using the length-prefixed form for values with newlines
+func writeJournalField(b *bytes.Buffer, name, value string) {
+	if !strings.ContainsRune(value, '\n') {
+		b.WriteString(name + "=" + value + "\n")
+		return
+	}
+
+	b.WriteString(name + "\n")
+	binary.Write(b, binary.LittleEndian, uint64(len(value)))
+	b.WriteString(value + "\n")
+}
+
+// journalFieldName converts a field key to a valid journal field name: uppercase letters,
+// digits and underscores, not starting with an underscore, which journald reserves, or a
+// digit. Keys naming one of the sink's own fields, such as "message", are prefixed so
+// they can't add a second MESSAGE or PRIORITY.
+func journalFieldName(key string) string {
+	name := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_':
+			return r
+		default:
+			return '_'
+		}
+	}, key)
+
+	name = strings.TrimLeft(name, "_")
+	if name == "" {
+		return ""
+	}
+	if journalOwnFields[name] || (name[0] >= '0' && name[0] <= '9') {
+		name = journalFieldPrefix + name
+	}
+	if len(name) > maxJournalFieldName {
+		name = name[:maxJournalFieldName]
+	}
+
+	return name
+}
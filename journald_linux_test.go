@@ -0,0 +1,137 @@
+package ilogger
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// mockJournal listens on a temporary unixgram socket the way journald does
+func mockJournal(t *testing.T) (path string, conn *net.UnixConn) {
+	t.Helper()
+
+	path = filepath.Join(t.TempDir(), "journal.socket")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	return path, conn
+}
+
+// readJournal reads one datagram and decodes its fields, in either serialization
+func readJournal(t *testing.T, conn *net.UnixConn) map[string][]string {
+	t.Helper()
+
+	buf := make([]byte, 64<<10)
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, err := conn.Read(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fields := map[string][]string{}
+	b := buf[:n]
+	for len(b) > 0 {
+		nl := bytes.IndexByte(b, '\n')
+		if nl < 0 {
+			t.Fatalf("unterminated field %q", b)
+		}
+		line := b[:nl]
+		b = b[nl+1:]
+
+		if eq := bytes.IndexByte(line, '='); eq >= 0 {
+			fields[string(line[:eq])] = append(fields[string(line[:eq])], string(line[eq+1:]))
+			continue
+		}
+		size := binary.LittleEndian.Uint64(b)
+		value := string(b[8 : 8+size])
+		b = b[8+size+1:]
+		fields[string(line)] = append(fields[string(line)], value)
+	}
+
+	return fields
+}
+
+func TestJournalPriorities(t *testing.T) {
+	path, conn := mockJournal(t)
+	sink, err := NewJournalSinkAt(path, "billing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sink.Close()
+
+	priorities := map[LogLevel]string{
+		LMandatory: "5",
+		LError:     "3",
+		LWarn:      "4",
+		LInfo:      "6",
+		LDebug:     "7",
+	}
+	for level, want := range priorities {
+		if err := sink.WriteEntry(Entry{Level: level, Message: "m"}); err != nil {
+			t.Fatal(err)
+		}
+		got := readJournal(t, conn)
+		if !reflect.DeepEqual(got["PRIORITY"], []string{want}) {
+			t.Errorf("%v: PRIORITY = %v, want %s", level, got["PRIORITY"], want)
+		}
+		if !reflect.DeepEqual(got["SYSLOG_IDENTIFIER"], []string{"billing"}) {
+			t.Errorf("%v: SYSLOG_IDENTIFIER = %v", level, got["SYSLOG_IDENTIFIER"])
+		}
+	}
+}
+
+func TestJournalFields(t *testing.T) {
+	path, conn := mockJournal(t)
+	sink, err := NewJournalSinkAt(path, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sink.Close()
+
+	err = sink.WriteEntry(Entry{
+		Level:     LError,
+		Message:   "first line\nsecond line",
+		Caller:    "/src/app/pay.go:88",
+		Component: "payments",
+		Fields: map[string]interface{}{
+			"message":  "user supplied",
+			"priority": 0,
+			"2fa":      true,
+			"_pid":     1,
+			"order.id": 42,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := readJournal(t, conn)
+	want := map[string][]string{
+		"MESSAGE":        {"first line\nsecond line"},
+		"PRIORITY":       {"3"},
+		"CODE_FILE":      {"/src/app/pay.go"},
+		"CODE_LINE":      {"88"},
+		"COMPONENT":      {"payments"},
+		"FIELD_MESSAGE":  {"user supplied"},
+		"FIELD_PRIORITY": {"0"},
+		"FIELD_2FA":      {"true"},
+		"PID":            {"1"},
+		"ORDER_ID":       {"42"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestJournalUnreachable(t *testing.T) {
+	if _, err := NewJournalSinkAt(filepath.Join(t.TempDir(), "missing.socket"), ""); err == nil {
+		t.Error("connecting to a missing socket succeeded")
+	}
+}
@@ -0,0 +1,32 @@
+//go:build !linux
+// +build !linux
+
+package ilogger
+
+import "errors"
+
+// JournalSink sends entries to the systemd journal; it is only available on linux
+type JournalSink struct {
+	// Identifier is sent as SYSLOG_IDENTIFIER when set
+	Identifier string
+}
+
+// NewJournalSink always fails outside linux
+func NewJournalSink(identifier string) (*JournalSink, error) {
+	return nil, errors.New("journald is only supported on linux")
+}
+
+// NewJournalSinkAt always fails outside linux
+func NewJournalSinkAt(path, identifier string) (*JournalSink, error) {
+	return nil, errors.New("journald is only supported on linux")
+}
+
+// WriteEntry implements Sink
+func (j *JournalSink) WriteEntry(e Entry) error {
+	return errors.New("journald is only supported on linux")
+}
+
+// Close implements io.Closer
+func (j *JournalSink) Close() error {
+	return nil
+}
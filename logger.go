@@ -0,0 +1,17 @@
+package ilogger
+
+// Logger is the logging surface of *ILog, for code that wants to accept a mock in tests
+type Logger interface {
+	Log(level LogLevel, formattedString string, params ...interface{})
+	Logf(level LogLevel, formattedString string, params ...interface{})
+	Mandatory(formattedString string, params ...interface{})
+	Error(err error)
+	Errorf(formattedString string, params ...interface{})
+	Warn(formattedString string, params ...interface{})
+	Info(formattedString string, params ...interface{})
+	Debug(formattedString string, params ...interface{})
+	Fatalf(formattedString string, params ...interface{})
+	Panic(formattedString string, params ...interface{})
+}
+
+var _ Logger = (*ILog)(nil)
@@ -0,0 +1,84 @@
+package ilogger
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"testing"
+)
+
+// mockLogger is a Logger recording each call as "level message"
+type mockLogger struct {
+	calls []string
+}
+
+func (m *mockLogger) record(level, format string, params []interface{}) {
+	m.calls = append(m.calls, level+" "+fmt.Sprintf(format, params...))
+}
+
+func (m *mockLogger) Log(level LogLevel, format string, params ...interface{}) {
+	m.record(level.String(), format, params)
+}
+
+func (m *mockLogger) Logf(level LogLevel, format string, params ...interface{}) {
+	m.record(level.String(), format, params)
+}
+
+func (m *mockLogger) Mandatory(format string, params ...interface{}) {
+	m.record("MANDATORY", format, params)
+}
+
+func (m *mockLogger) Error(err error) {
+	m.record("ERROR", "%v", []interface{}{err})
+}
+
+func (m *mockLogger) Errorf(format string, params ...interface{}) {
+	m.record("ERROR", format, params)
+}
+
+func (m *mockLogger) Warn(format string, params ...interface{}) {
+	m.record("WARN", format, params)
+}
+
+func (m *mockLogger) Info(format string, params ...interface{}) {
+	m.record("INFO", format, params)
+}
+
+func (m *mockLogger) Debug(format string, params ...interface{}) {
+	m.record("DEBUG", format, params)
+}
+
+func (m *mockLogger) Fatalf(format string, params ...interface{}) {
+	m.record("FATAL", format, params)
+}
+
+func (m *mockLogger) Panic(format string, params ...interface{}) {
+	m.record("PANIC", format, params)
+}
+
+// chargeCard stands in for consumer code that depends on the interface
+func chargeCard(l Logger, amount int) {
+	if amount <= 0 {
+		l.Error(errors.New("invalid amount"))
+		return
+	}
+	l.Info("charged %d", amount)
+}
+
+func TestLoggerInterface(t *testing.T) {
+	var _ Logger = (*ILog)(nil)
+
+	m := &mockLogger{}
+	chargeCard(m, 5)
+	chargeCard(m, 0)
+	if want := []string{"INFO charged 5", "ERROR invalid amount"}; !reflect.DeepEqual(m.calls, want) {
+		t.Errorf("mock calls = %q, want %q", m.calls, want)
+	}
+
+	l := newTestLogger(t, &ILog{})
+	chargeCard(l, 5)
+	if got := readLog(t, l); !regexp.MustCompile(`INFO - charged 5\n$`).MatchString(got) {
+		t.Errorf("ILog got %q", got)
+	}
+}
@@ -0,0 +1,164 @@
+package ilogger
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"strings"
+)
+
+// Sink receives every written entry in addition to the log file, for backends that
+// need the entry's level and fields rather than an encoded line
+type Sink interface {
+	WriteEntry(e Entry) error
+}
+
+// scopedOutput is an extra writer attached with AddScopedOutput or AddLevelOutput, or a
+// sink added with AddSink
+type scopedOutput struct {
+	w    io.Writer
+	sink Sink
+
+	// levels limits the output to these levels when nonzero
+	levels LogLevel
+	// enc renders entries for this output in place of the logger's encoder when set
+	enc Encoder
+}
+
+// AddScopedOutput also writes every line to w until the returned func is called;
+// it is meant for capturing the logs of a single operation while logging normally
+func (i *ILog) AddScopedOutput(w io.Writer) (remove func()) {
+	return i.addOutput(&scopedOutput{w: w})
+}
+
+// AddLevelOutput writes entries at levels (e.g. LError|LWarn) to w, rendered with enc
+// rather than the logger's encoder, until the returned func is called; a nil enc keeps
+// the logger's lines. It lets e.g. errors go to an alerting sink as JSON while text
+// goes to the console.
+func (i *ILog) AddLevelOutput(w io.Writer, levels LogLevel, enc Encoder) (remove func()) {
+	return i.addOutput(&scopedOutput{w: w, levels: levels, enc: enc})
+}
+
+// AddSink sends every entry to s until the returned func is called
+func (i *ILog) AddSink(s Sink) (remove func()) {
+	return i.addOutput(&scopedOutput{sink: s})
+}
+
+// addOutput attaches o and returns the func that detaches it
+func (i *ILog) addOutput(o *scopedOutput) (remove func()) {
+	i.outMu.Lock()
+	i.outputs = append(i.outputs, o)
+	i.outMu.Unlock()
+
+	return func() {
+		i.outMu.Lock()
+		defer i.outMu.Unlock()
+
+		for n, cur := range i.outputs {
+			if cur == o {
+				i.outputs = append(i.outputs[:n], i.outputs[n+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// writeOutputs copies encoded lines to the scoped outputs and their entries to the sinks;
+// callers must hold i.mu for the redaction patterns
+func (i *ILog) writeOutputs(entries []Entry, lines [][]byte) {
+	// held across the writes too, since the writers need not be safe for concurrent use
+	i.outMu.Lock()
+	defer i.outMu.Unlock()
+
+	for _, o := range i.outputs {
+		for n, e := range entries {
+			if o.levels != 0 && e.Level&o.levels == 0 {
+				continue
+			}
+			if o.sink != nil {
+				if err := o.sink.WriteEntry(e); err != nil {
+					log.Printf("unable to write to log sink: %+v", err)
+				}
+				continue
+			}
+
+			line := lines[n]
+			if o.enc != nil {
+				line = i.redactLine(o.enc.Encode(e))
+				if i.LengthPrefix {
+					line = frame(line)
+				}
+			}
+			if _, err := o.w.Write(line); err != nil {
+				log.Printf("unable to write to scoped log output: %+v", err)
+			}
+		}
+	}
+}
+
+// mirrorStdlib writes entries to log.Default() as text without a header of their own,
+// since the standard logger adds its own timestamp; redaction still applies
+func (i *ILog) mirrorStdlib(entries []Entry) {
+	// Lmsgprefix alone is nonzero, so DefaultFlags don't apply, yet selects no header
+	enc := TextEncoder{Flags: log.Lmsgprefix, AllowControls: i.AllowControls, NoColor: true, CompactLevel: i.CompactLevel, FieldOrder: i.FieldOrder}
+
+	std := log.Default()
+	for _, e := range entries {
+		if err := std.Output(2, string(i.redactLine(enc.Encode(e)))); err != nil {
+			log.Printf("unable to mirror log entry: %+v", err)
+		}
+	}
+}
+
+// flushOutputs flushes every scoped output and sink with a Flush or Sync method,
+// carrying on past failures
+func (i *ILog) flushOutputs() multiError {
+	i.outMu.Lock()
+	defer i.outMu.Unlock()
+
+	var errs multiError
+	for _, o := range i.outputs {
+		var target interface{} = o.w
+		if o.sink != nil {
+			target = o.sink
+		}
+
+		var err error
+		switch t := target.(type) {
+		case interface{ Flush() error }:
+			err = t.Flush()
+		case interface{ Sync() error }:
+			err = t.Sync()
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("output %T: %w", target, err))
+		}
+	}
+
+	return errs
+}
+
+// multiError collects the failures of several outputs
+type multiError []error
+
+// Error lists every failure
+func (m multiError) Error() string {
+	msgs := make([]string, len(m))
+	for n, err := range m {
+		msgs[n] = err.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Unwrap exposes the individual failures to errors.Is and errors.As on Go 1.20 and later
+func (m multiError) Unwrap() []error {
+	return m
+}
+
+// err returns m as an error, or nil when nothing failed
+func (m multiError) err() error {
+	if len(m) == 0 {
+		return nil
+	}
+	return m
+}
@@ -0,0 +1,185 @@
+package ilogger
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestScopedOutput(t *testing.T) {
+	l := newTestLogger(t, &ILog{Flags: log.Lmsgprefix})
+
+	var scoped bytes.Buffer
+	l.Info("before")
+	remove := l.AddScopedOutput(&scoped)
+	l.Info("during")
+	remove()
+	l.Info("after")
+
+	if got := scoped.String(); got != "INFO - during\n" {
+		t.Errorf("scoped output = %q, want only the entry logged while attached", got)
+	}
+	if got := readLog(t, l); got != "INFO - before\nINFO - during\nINFO - after\n" {
+		t.Errorf("file = %q, want every entry", got)
+	}
+}
+
+// flushWriter is an output recording its Flush calls and failing them with err
+type flushWriter struct {
+	bytes.Buffer
+	flushes int
+	err     error
+}
+
+func (w *flushWriter) Flush() error {
+	w.flushes++
+	return w.err
+}
+
+func TestFlushAttemptsEveryOutput(t *testing.T) {
+	l := newTestLogger(t, &ILog{})
+
+	errFull := errors.New("disk full")
+	failing := &flushWriter{err: errFull}
+	working := &flushWriter{}
+	l.AddScopedOutput(failing)
+	l.AddScopedOutput(working)
+	l.Info("entry")
+
+	err := l.Sync()
+	if failing.flushes != 1 || working.flushes != 1 {
+		t.Errorf("Sync flushed the outputs %d and %d times, want once each", failing.flushes, working.flushes)
+	}
+	if !errors.Is(err, errFull) || strings.Count(err.Error(), "disk full") != 1 {
+		t.Errorf("Sync error = %v, want the one failure", err)
+	}
+
+	failing.err = nil
+	working.err = errors.New("broken pipe")
+	err = l.Close()
+	if failing.flushes != 2 || working.flushes != 2 {
+		t.Errorf("Close flushed the outputs %d and %d times, want once more each", failing.flushes, working.flushes)
+	}
+	if err == nil || !strings.Contains(err.Error(), "broken pipe") || strings.Contains(err.Error(), "disk full") {
+		t.Errorf("Close error = %v, want only the new failure", err)
+	}
+}
+
+// entrySink is a Sink keeping every entry it receives
+type entrySink struct {
+	entries []Entry
+}
+
+func (s *entrySink) WriteEntry(e Entry) error {
+	s.entries = append(s.entries, e)
+	return nil
+}
+
+func TestRedactPatterns(t *testing.T) {
+	l := newTestLogger(t, &ILog{Flags: log.Lmsgprefix, Component: "ann@example.com"})
+	l.RedactPatterns([]*regexp.Regexp{
+		regexp.MustCompile(`[\w.+-]+@[\w-]+\.[\w.]+`),
+		regexp.MustCompile(`\b(?:\d[ -]?){13,16}\b`),
+	})
+
+	sink := &entrySink{}
+	l.AddSink(sink)
+	var json bytes.Buffer
+	l.AddLevelOutput(&json, LInfo, JSONEncoder{})
+	var hooked string
+	l.OnLog = func(_ LogLevel, msg string) { hooked = msg }
+
+	fields := map[string]interface{}{"card": "4111 1111 1111 1111", "qty": 2}
+	l.LogTags(LInfo, []string{"bob@example.com"}, "paid with %s", "4111-1111-1111-1111")
+	l.LogBatch([]Entry{{Level: LInfo, Message: "order", Fields: fields}})
+
+	want := "[REDACTED]: INFO - paid with [REDACTED] [[REDACTED]]\n[REDACTED]: INFO - order card=\"[REDACTED]\" qty=2\n"
+	if got := readLog(t, l); got != want {
+		t.Errorf("file = %q, want %q", got, want)
+	}
+	if got := json.String(); strings.Contains(got, "@example.com") || strings.Contains(got, "1111") {
+		t.Errorf("JSON output leaked: %s", got)
+	}
+	if hooked != "order" {
+		t.Errorf("OnLog saw %q", hooked)
+	}
+
+	if len(sink.entries) != 2 {
+		t.Fatalf("sink got %d entries, want 2", len(sink.entries))
+	}
+	e := sink.entries[0]
+	if e.Message != "paid with [REDACTED]" || e.Component != redacted || len(e.Tags) != 1 || e.Tags[0] != redacted {
+		t.Errorf("sink entry not masked: %+v", e)
+	}
+	if got := sink.entries[1].Fields; got["card"] != redacted || got["qty"] != 2 {
+		t.Errorf("sink fields not masked: %v", got)
+	}
+	if fields["card"] != "4111 1111 1111 1111" {
+		t.Error("redaction changed the caller's fields")
+	}
+}
+
+func TestMirrorStdlib(t *testing.T) {
+	var std bytes.Buffer
+	log.SetOutput(&std)
+	flags := log.Flags()
+	log.SetFlags(0)
+	defer func() {
+		log.SetOutput(os.Stderr)
+		log.SetFlags(flags)
+	}()
+
+	l := newTestLogger(t, &ILog{Flags: log.Lmsgprefix, MirrorStdlib: true, Component: "api"})
+	l.Warn("disk at %d%%", 91)
+	l.Debug("detail")
+
+	want := "api: WARN - disk at 91%\napi: DEBUG - detail\n"
+	if got := readLog(t, l); got != want {
+		t.Errorf("file = %q, want %q", got, want)
+	}
+	if got := std.String(); got != want {
+		t.Errorf("stdlib output = %q, want %q", got, want)
+	}
+}
+
+func TestLevelOutput(t *testing.T) {
+	l := newTestLogger(t, &ILog{Flags: log.Lmsgprefix})
+
+	var console, alerts, plain bytes.Buffer
+	l.AddLevelOutput(&console, LDebug|LInfo, TextEncoder{Flags: log.Lmsgprefix, NoColor: true})
+	removeAlerts := l.AddLevelOutput(&alerts, LError|LWarn, JSONEncoder{})
+	l.AddLevelOutput(&plain, 0, nil)
+
+	l.Debug("cache miss")
+	l.Info("started")
+	l.Errorf("charge failed")
+	l.Warn("slow")
+	removeAlerts()
+	l.Errorf("after removal")
+
+	if got, want := console.String(), "DEBUG - cache miss\nINFO - started\n"; got != want {
+		t.Errorf("console = %q, want %q", got, want)
+	}
+
+	var entries []map[string]interface{}
+	for _, line := range lines(alerts.String()) {
+		var e map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &e); err != nil {
+			t.Fatalf("alert line %q is not JSON: %v", line, err)
+		}
+		entries = append(entries, e)
+	}
+	if len(entries) != 2 || entries[0]["level"] != "ERROR" || entries[0]["msg"] != "charge failed" || entries[1]["level"] != "WARN" {
+		t.Errorf("alerts = %v, want the error and warning as JSON", entries)
+	}
+
+	// no levels and no encoder takes every line as the logger renders it
+	if got := plain.String(); got != readLog(t, l) {
+		t.Errorf("plain output = %q, want the file's lines %q", got, readLog(t, l))
+	}
+}
@@ -0,0 +1,27 @@
+package ilogger
+
+import "time"
+
+// LogQuery logs a database query at level with "query", "duration_ms" and "args" fields,
+// standardizing slow-query logging; with RedactQueryArgs each arg is written as "[REDACTED]"
+func (i *ILog) LogQuery(level LogLevel, query string, args []interface{}, d time.Duration) {
+	if !i.enabled(level) {
+		return
+	}
+
+	logged := args
+	if i.RedactQueryArgs && len(args) > 0 {
+		logged = make([]interface{}, len(args))
+		for n := range logged {
+			logged[n] = redacted
+		}
+	}
+
+	fields := map[string]interface{}{"query": query, "duration_ms": millis(d)}
+	if len(logged) > 0 {
+		fields["args"] = logged
+	}
+
+	// skip entry, logOp and LogQuery to reach the caller
+	i.logOp(3, level, "query", fields)
+}
@@ -0,0 +1,51 @@
+package ilogger
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestLogQuery(t *testing.T) {
+	l := newTestLogger(t, &ILog{Encoder: JSONEncoder{}})
+	args := []interface{}{"ann@example.com", 42}
+	l.LogQuery(LWarn, "SELECT * FROM users WHERE email = ? AND org = ?", args, 1500*time.Microsecond)
+	l.LogQuery(LInfo, "SELECT 1", nil, time.Millisecond)
+
+	redacting := newTestLogger(t, &ILog{Encoder: JSONEncoder{}, RedactQueryArgs: true})
+	secret := []interface{}{"hunter2"}
+	redacting.LogQuery(LWarn, "UPDATE users SET password = ?", secret, 2*time.Second)
+
+	quiet := newTestLogger(t, &ILog{Encoder: JSONEncoder{}, Level: LError})
+	quiet.LogQuery(LInfo, "SELECT 1", nil, time.Millisecond)
+
+	entries := jsonEntries(t, l)
+	if len(entries) != 2 {
+		t.Fatalf("got %d entries, want 2", len(entries))
+	}
+	e := entries[0]
+	if e["level"] != "WARN" || e["msg"] != "query" || e["query"] != "SELECT * FROM users WHERE email = ? AND org = ?" {
+		t.Errorf("entry = %v, want the query at WARN", e)
+	}
+	if e["duration_ms"] != 1.5 {
+		t.Errorf("duration_ms = %v, want 1.5", e["duration_ms"])
+	}
+	if want := []interface{}{"ann@example.com", float64(42)}; !reflect.DeepEqual(e["args"], want) {
+		t.Errorf("args = %v, want %v", e["args"], want)
+	}
+	if _, ok := entries[1]["args"]; ok {
+		t.Error("args written for a query without any")
+	}
+
+	r := jsonEntries(t, redacting)[0]
+	if want := []interface{}{"[REDACTED]"}; !reflect.DeepEqual(r["args"], want) || r["duration_ms"] != 2000.0 {
+		t.Errorf("redacted entry = %v, want args %v and duration_ms 2000", r, want)
+	}
+	if secret[0] != "hunter2" {
+		t.Error("LogQuery changed the caller's args")
+	}
+
+	if got := readLog(t, quiet); got != "" {
+		t.Errorf("query logged below the level: %q", got)
+	}
+}
@@ -0,0 +1,233 @@
+package ilogger
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+)
+
+// Ring file layout: a header of the magic, the data capacity and the head and tail offsets,
+// then the data area holding length-prefixed records as a circular buffer. head and tail
+// only grow; their positions in the data area are taken modulo the capacity.
+const (
+	ringMagic      = "ILOGRNG1"
+	ringHeaderSize = 32
+)
+
+// MmapRing is a File over a memory-mapped file of fixed size, kept as a ring of records
+// so lines are stored without a write syscall each; once full, the oldest records are
+// overwritten. Set it as ILog.Ring to log into it in place of files, or attach it as an
+// extra output with AddScopedOutput, and read it back with ReadRing.
+type MmapRing struct {
+	mu   sync.Mutex
+	f    *os.File
+	mem  []byte
+	data []byte
+}
+
+// OpenMmapRing maps the ring file at path with size bytes of record space, creating or
+// resetting it unless it is already a ring of that size, in which case writing carries on
+func OpenMmapRing(path string, size int) (*MmapRing, error) {
+	if size <= 4 {
+		return nil, fmt.Errorf("ring size %d is too small", size)
+	}
+
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	total := int64(ringHeaderSize + size)
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	if info.Size() != total {
+		if err := f.Truncate(total); err != nil {
+			f.Close()
+			return nil, err
+		}
+	}
+
+	mem, err := mapFile(f, int(total))
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	r := &MmapRing{f: f, mem: mem, data: mem[ringHeaderSize:]}
+	if string(mem[:len(ringMagic)]) != ringMagic || binary.BigEndian.Uint64(mem[8:]) != uint64(size) {
+		copy(mem, ringMagic)
+		binary.BigEndian.PutUint64(mem[8:], uint64(size))
+		r.setHead(0)
+		r.setTail(0)
+	}
+
+	return r, nil
+}
+
+// offsets returns the head and tail offsets from the header
+func (r *MmapRing) offsets() (head, tail uint64) {
+	return binary.BigEndian.Uint64(r.mem[16:]), binary.BigEndian.Uint64(r.mem[24:])
+}
+
+// setHead stores the head offset in the header
+func (r *MmapRing) setHead(n uint64) {
+	binary.BigEndian.PutUint64(r.mem[16:], n)
+}
+
+// setTail stores the tail offset in the header
+func (r *MmapRing) setTail(n uint64) {
+	binary.BigEndian.PutUint64(r.mem[24:], n)
+}
+
+// Write stores each line of p as a record, dropping the oldest records to make room;
+// newlines are left off since records are length-prefixed. Lines arrive together from
+// LogBatch and WriteBufferBytes, so each still reads back as one record.
+func (r *MmapRing) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.mem == nil {
+		return 0, os.ErrClosed
+	}
+
+	var records [][]byte
+	for rest := p; len(rest) > 0; {
+		n := bytes.IndexByte(rest, '\n')
+		if n < 0 {
+			n = len(rest) - 1
+		}
+		rec := bytes.TrimSuffix(rest[:n+1], []byte{'\n'})
+		if uint64(4+len(rec)) > uint64(len(r.data)) {
+			return 0, fmt.Errorf("record of %d bytes exceeds the ring size %d", len(rec), len(r.data))
+		}
+		records = append(records, rec)
+		rest = rest[n+1:]
+	}
+	for _, rec := range records {
+		r.store(rec)
+	}
+
+	return len(p), nil
+}
+
+// store writes rec as the newest record; callers must hold r.mu
+func (r *MmapRing) store(rec []byte) {
+	size := uint64(len(r.data))
+	need := uint64(4 + len(rec))
+
+	head, tail := r.offsets()
+	for head+need-tail > size {
+		var n [4]byte
+		ringRead(r.data, tail, n[:])
+		tail += 4 + uint64(binary.BigEndian.Uint32(n[:]))
+	}
+	r.setTail(tail)
+
+	var n [4]byte
+	binary.BigEndian.PutUint32(n[:], uint32(len(rec)))
+	ringWrite(r.data, head, n[:])
+	ringWrite(r.data, head+4, rec)
+	// the head moves last, so a reader never sees a record before it is complete
+	r.setHead(head + need)
+}
+
+// Name implements File with the ring file's path
+func (r *MmapRing) Name() string {
+	return r.f.Name()
+}
+
+// Stat implements File
+func (r *MmapRing) Stat() (os.FileInfo, error) {
+	return r.f.Stat()
+}
+
+// Sync flushes the mapped pages to the file
+func (r *MmapRing) Sync() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.mem == nil {
+		return os.ErrClosed
+	}
+	return syncMap(r.mem)
+}
+
+// Close syncs and unmaps the ring and closes its file
+func (r *MmapRing) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.mem == nil {
+		return nil
+	}
+
+	var errs multiError
+	if err := syncMap(r.mem); err != nil {
+		errs = append(errs, err)
+	}
+	if err := unmapFile(r.mem); err != nil {
+		errs = append(errs, err)
+	}
+	r.mem, r.data = nil, nil
+	if err := r.f.Close(); err != nil {
+		errs = append(errs, err)
+	}
+
+	return errs.err()
+}
+
+// ReadRing returns the records in the ring file at path, oldest first
+func ReadRing(path string) ([][]byte, error) {
+	mem, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(mem) < ringHeaderSize || string(mem[:len(ringMagic)]) != ringMagic {
+		return nil, errors.New("not an ilogger ring file")
+	}
+
+	size := binary.BigEndian.Uint64(mem[8:])
+	head, tail := binary.BigEndian.Uint64(mem[16:]), binary.BigEndian.Uint64(mem[24:])
+	data := mem[ringHeaderSize:]
+	if uint64(len(data)) != size || tail > head || head-tail > size {
+		return nil, errors.New("corrupt ilogger ring file")
+	}
+
+	var records [][]byte
+	for pos := tail; pos < head; {
+		var n [4]byte
+		ringRead(data, pos, n[:])
+		l := uint64(binary.BigEndian.Uint32(n[:]))
+		if pos+4+l > head {
+			return records, errors.New("corrupt ilogger ring record")
+		}
+
+		rec := make([]byte, l)
+		ringRead(data, pos+4, rec)
+		records = append(records, rec)
+		pos += 4 + l
+	}
+
+	return records, nil
+}
+
+// ringWrite copies p into data at offset pos, wrapping around the end
+func ringWrite(data []byte, pos uint64, p []byte) {
+	at := int(pos % uint64(len(data)))
+	n := copy(data[at:], p)
+	copy(data, p[n:])
+}
+
+// ringRead fills p from data at offset pos, wrapping around the end
+func ringRead(data []byte, pos uint64, p []byte) {
+	at := int(pos % uint64(len(data)))
+	n := copy(p, data[at:])
+	copy(p[n:], data)
+}
@@ -0,0 +1,27 @@
+//go:build !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd
+// +build !darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd
+
+package ilogger
+
+import (
+	"errors"
+	"os"
+)
+
+// errNoMmap is returned by OpenMmapRing where mmap isn't supported
+var errNoMmap = errors.New("memory-mapped rings are only supported on unix")
+
+// mapFile always fails where mmap isn't supported
+func mapFile(f *os.File, size int) ([]byte, error) {
+	return nil, errNoMmap
+}
+
+// unmapFile is a no-op where mmap isn't supported
+func unmapFile(b []byte) error {
+	return nil
+}
+
+// syncMap is a no-op where mmap isn't supported
+func syncMap(b []byte) error {
+	return nil
+}
@@ -0,0 +1,25 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+// +build darwin dragonfly freebsd linux netbsd openbsd
+
+package ilogger
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// mapFile maps the first size bytes of f for reading and writing, shared with the file
+func mapFile(f *os.File, size int) ([]byte, error) {
+	return unix.Mmap(int(f.Fd()), 0, size, unix.PROT_READ|unix.PROT_WRITE, unix.MAP_SHARED)
+}
+
+// unmapFile releases a mapping from mapFile
+func unmapFile(b []byte) error {
+	return unix.Munmap(b)
+}
+
+// syncMap writes the mapping's dirty pages back to the file
+func syncMap(b []byte) error {
+	return unix.Msync(b, unix.MS_SYNC)
+}
@@ -0,0 +1,168 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+// +build darwin dragonfly freebsd linux netbsd openbsd
+
+package ilogger
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// ringStrings reads the ring file at path as strings
+func ringStrings(tb testing.TB, path string) []string {
+	tb.Helper()
+
+	records, err := ReadRing(path)
+	if err != nil {
+		tb.Fatalf("ReadRing: %v", err)
+	}
+	var got []string
+	for _, rec := range records {
+		got = append(got, string(rec))
+	}
+	return got
+}
+
+func TestMmapRing(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.ring")
+	ring, err := OpenMmapRing(path, 4096)
+	if err != nil {
+		t.Fatalf("OpenMmapRing: %v", err)
+	}
+	defer ring.Close()
+
+	l := newTestLogger(t, &ILog{Flags: log.Lmsgprefix})
+	remove := l.AddScopedOutput(ring)
+	l.Info("one")
+	l.Warn("two")
+	remove()
+	if err := ring.Sync(); err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+
+	if got, want := ringStrings(t, path), []string{"INFO - one", "WARN - two"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestMmapRingWraparound(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.ring")
+	// room for 3 records of 4+10 bytes, so each lap lands at a different offset
+	ring, err := OpenMmapRing(path, 45)
+	if err != nil {
+		t.Fatalf("OpenMmapRing: %v", err)
+	}
+
+	for n := 0; n < 20; n++ {
+		if _, err := fmt.Fprintf(ring, "record %03d\n", n); err != nil {
+			t.Fatalf("Write %d: %v", n, err)
+		}
+		var want []string
+		for k := n - 2; k <= n; k++ {
+			if k >= 0 {
+				want = append(want, fmt.Sprintf("record %03d", k))
+			}
+		}
+		if got := ringStrings(t, path); !reflect.DeepEqual(got, want) {
+			t.Fatalf("after record %d got %q, want %q", n, got, want)
+		}
+	}
+	if err := ring.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	// reopening at the same size carries on from the existing records
+	ring, err = OpenMmapRing(path, 45)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer ring.Close()
+	ring.Write([]byte("record 020"))
+	if got, want := ringStrings(t, path), []string{"record 018", "record 019", "record 020"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("after reopening got %q, want %q", got, want)
+	}
+
+	if _, err := ring.Write(make([]byte, 42)); err == nil {
+		t.Error("a record larger than the ring was accepted")
+	}
+}
+
+func TestReadRingRejectsOtherFiles(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	if err := ioutil.WriteFile(path, []byte("INFO - not a ring file, just a log\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ReadRing(path); err == nil {
+		t.Error("ReadRing accepted a file without the ring magic")
+	}
+}
+
+func TestMmapRingDestination(t *testing.T) {
+	for _, buffered := range []int{0, 1 << 10} {
+		path := filepath.Join(t.TempDir(), "app.ring")
+		ring, err := OpenMmapRing(path, 4096)
+		if err != nil {
+			t.Fatalf("OpenMmapRing: %v", err)
+		}
+		defer ring.Close()
+
+		fs := &countingFS{}
+		dir := t.TempDir()
+		l := newTestLogger(t, &ILog{Path: dir, FS: fs, Ring: ring, Flags: log.Lmsgprefix, MaxBytes: 64, WriteBufferBytes: buffered})
+		var want []string
+		for n := 0; n < 20; n++ {
+			l.Info("entry %d", n)
+			want = append(want, fmt.Sprintf("INFO - entry %d", n))
+		}
+		l.LogBatch([]Entry{{Level: LWarn, Message: "one"}, {Level: LWarn, Message: "two"}})
+		want = append(want, "WARN - one", "WARN - two")
+		if err := l.Sync(); err != nil {
+			t.Fatalf("Sync: %v", err)
+		}
+
+		if got := ringStrings(t, path); !reflect.DeepEqual(got, want) {
+			t.Errorf("buffer %d: ring holds %q, want %q", buffered, got, want)
+		}
+		if fs.writes != 0 {
+			t.Errorf("buffer %d: %d file writes, want none with the ring", buffered, fs.writes)
+		}
+		if names, _ := filepath.Glob(filepath.Join(dir, "*")); len(names) != 0 {
+			t.Errorf("buffer %d: files created alongside the ring: %v", buffered, names)
+		}
+
+		// the ring belongs to the caller, so closing the logger leaves it usable
+		l.Close()
+		if _, err := ring.Write([]byte("after close\n")); err != nil {
+			t.Errorf("buffer %d: ring closed with the logger: %v", buffered, err)
+		}
+	}
+}
+
+func TestMmapRingWriteSplitsLines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.ring")
+	ring, err := OpenMmapRing(path, 64)
+	if err != nil {
+		t.Fatalf("OpenMmapRing: %v", err)
+	}
+	defer ring.Close()
+
+	if n, err := ring.Write([]byte("one\ntwo\nthree")); n != 13 || err != nil {
+		t.Errorf("Write = %d, %v", n, err)
+	}
+	if got, want := ringStrings(t, path), []string{"one", "two", "three"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	// a line too large for the ring rejects the whole write
+	if _, err := ring.Write([]byte("four\n" + strings.Repeat("x", 64) + "\n")); err == nil {
+		t.Error("a line larger than the ring was accepted")
+	}
+	if got := ringStrings(t, path); len(got) != 3 {
+		t.Errorf("rejected write stored records: %q", got)
+	}
+}
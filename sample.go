@@ -0,0 +1,92 @@
+package ilogger
+
+import (
+	"sync"
+	"time"
+)
+
+// sampleWindow is the open SampleWindow for one level
+type sampleWindow struct {
+	// last is the latest entry after the first, held until the window closes
+	last    *Entry
+	skipped int
+	// timer closes the window when SampleWindow has passed
+	timer *time.Timer
+}
+
+// windowSampler holds the state behind ILog.SampleWindow
+type windowSampler struct {
+	mu      sync.Mutex
+	windows map[LogLevel]*sampleWindow
+}
+
+// sample passes the first entry of each level's window straight through and holds back
+// the rest, keeping only the latest; when the window closes that one is written with a
+// "skipped" field counting the entries between it and the first
+func (i *ILog) sample(entries []Entry) []Entry {
+	i.sampled.mu.Lock()
+	defer i.sampled.mu.Unlock()
+
+	if i.sampled.windows == nil {
+		i.sampled.windows = make(map[LogLevel]*sampleWindow)
+	}
+
+	kept := entries[:0]
+	for _, e := range entries {
+		w, ok := i.sampled.windows[e.Level]
+		if !ok {
+			w = &sampleWindow{}
+			i.sampled.windows[e.Level] = w
+			level := e.Level
+			w.timer = time.AfterFunc(i.SampleWindow, func() { i.closeSample(level, w) })
+			kept = append(kept, e)
+			continue
+		}
+
+		if w.last != nil {
+			w.skipped++
+		}
+		held := e
+		w.last = &held
+	}
+
+	return kept
+}
+
+// closeSample ends level's window w, writing its held entry; it does nothing once w has
+// been replaced, so a timer that fires after flushSamples closed its window early cannot
+// cut short the window opened after it
+func (i *ILog) closeSample(level LogLevel, w *sampleWindow) {
+	i.sampled.mu.Lock()
+	if i.sampled.windows[level] != w {
+		i.sampled.mu.Unlock()
+		return
+	}
+	delete(i.sampled.windows, level)
+	w.timer.Stop()
+	i.sampled.mu.Unlock()
+
+	if w.last == nil {
+		return
+	}
+
+	e := *w.last
+	if w.skipped > 0 {
+		e.Fields = withField(e.Fields, "skipped", w.skipped)
+	}
+	i.emit(e)
+}
+
+// flushSamples closes every open window early, e.g. on Close
+func (i *ILog) flushSamples() {
+	i.sampled.mu.Lock()
+	windows := make(map[LogLevel]*sampleWindow, len(i.sampled.windows))
+	for level, w := range i.sampled.windows {
+		windows[level] = w
+	}
+	i.sampled.mu.Unlock()
+
+	for level, w := range windows {
+		i.closeSample(level, w)
+	}
+}
@@ -0,0 +1,68 @@
+package ilogger
+
+import (
+	"log"
+	"testing"
+	"time"
+)
+
+func TestSampleWindow(t *testing.T) {
+	l := newTestLogger(t, &ILog{Flags: log.Lmsgprefix, SampleWindow: time.Hour})
+
+	for n := 1; n <= 5; n++ {
+		l.Info("tick %d", n)
+	}
+	l.Warn("only warning")
+	if got, want := readLog(t, l), "INFO - tick 1\nWARN - only warning\n"; got != want {
+		t.Errorf("while open got %q, want only the first of each level %q", got, want)
+	}
+
+	l.flushSamples()
+	want := "INFO - tick 1\nWARN - only warning\nINFO - tick 5 skipped=3\n"
+	if got := readLog(t, l); got != want {
+		t.Errorf("after closing got %q, want %q", got, want)
+	}
+}
+
+func TestSampleWindowTimer(t *testing.T) {
+	l := newTestLogger(t, &ILog{Flags: log.Lmsgprefix, SampleWindow: 20 * time.Millisecond})
+
+	l.Info("first")
+	l.Info("middle")
+	l.Info("last")
+
+	want := "INFO - first\nINFO - last skipped=1\n"
+	deadline := time.Now().Add(5 * time.Second)
+	for readLog(t, l) != want {
+		if time.Now().After(deadline) {
+			t.Fatalf("got %q, want %q once the window closes", readLog(t, l), want)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestSampleStaleTimer(t *testing.T) {
+	l := newTestLogger(t, &ILog{Flags: log.Lmsgprefix, SampleWindow: time.Hour})
+
+	l.Info("a")
+	l.sampled.mu.Lock()
+	stale := l.sampled.windows[LInfo]
+	l.sampled.mu.Unlock()
+	l.flushSamples()
+	if stale.timer.Stop() {
+		t.Error("closing the window early left its timer running")
+	}
+
+	l.Info("b1")
+	l.Info("b2")
+	// the first window's timer firing late must leave the second window open
+	l.closeSample(LInfo, stale)
+	if got, want := readLog(t, l), "INFO - a\nINFO - b1\n"; got != want {
+		t.Errorf("stale close got %q, want %q", got, want)
+	}
+
+	l.flushSamples()
+	if got, want := readLog(t, l), "INFO - a\nINFO - b1\nINFO - b2\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
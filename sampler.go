@@ -0,0 +1,28 @@
+package ilogger
+
+// Sampler decides which entries are written. ShouldLog is called once per entry that
+// passed the level check, with its formatted message; it must be safe for concurrent use.
+// RateSampler and DedupSampler are the built-in strategies.
+type Sampler interface {
+	ShouldLog(level LogLevel, msg string) bool
+}
+
+// SamplerFunc adapts a plain func to a Sampler
+type SamplerFunc func(level LogLevel, msg string) bool
+
+// ShouldLog calls f(level, msg)
+func (f SamplerFunc) ShouldLog(level LogLevel, msg string) bool {
+	return f(level, msg)
+}
+
+// sampleWith drops the entries i.Sampler rejects
+func (i *ILog) sampleWith(entries []Entry) []Entry {
+	kept := entries[:0]
+	for _, e := range entries {
+		if i.Sampler.ShouldLog(e.Level, e.Message) {
+			kept = append(kept, e)
+		}
+	}
+
+	return kept
+}
@@ -0,0 +1,63 @@
+package ilogger
+
+import (
+	"log"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSampler(t *testing.T) {
+	var seen []string
+	n := 0
+	// keep every other INFO and anything mentioning "payment"; other levels pass
+	l := newTestLogger(t, &ILog{Flags: log.Lmsgprefix, Sampler: SamplerFunc(func(level LogLevel, msg string) bool {
+		seen = append(seen, level.String()+":"+msg)
+		if level != LInfo || strings.Contains(msg, "payment") {
+			return true
+		}
+		n++
+		return n%2 == 1
+	})})
+
+	for k := 1; k <= 4; k++ {
+		l.Info("tick %d", k)
+	}
+	l.Info("payment received")
+	l.Errorf("failed")
+	l.Debug("debug passes")
+	l.LogBatch([]Entry{{Level: LInfo, Message: "batch 1"}, {Level: LInfo, Message: "batch 2"}})
+
+	want := "INFO - tick 1\nINFO - tick 3\nINFO - payment received\nERROR - failed\nDEBUG - debug passes\nINFO - batch 1\n"
+	if got := readLog(t, l); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if len(seen) != 9 || seen[0] != "INFO:tick 1" {
+		t.Errorf("sampler saw %q, want each entry once with its formatted message", seen)
+	}
+}
+
+func TestBuiltinSamplers(t *testing.T) {
+	rate := newTestLogger(t, &ILog{Flags: log.Lmsgprefix, Sampler: &RateSampler{Limits: map[LogLevel]int{LInfo: 3}}})
+	for k := 0; k < 10; k++ {
+		rate.Info("burst")
+		rate.Warn("warn")
+	}
+	out := readLog(t, rate)
+	if got := strings.Count(out, "INFO - burst"); got != 3 {
+		t.Errorf("RateSampler kept %d INFO entries of a 10 burst, want 3", got)
+	}
+	if got := strings.Count(out, "WARN - warn"); got != 10 {
+		t.Errorf("RateSampler kept %d unlimited WARN entries, want 10", got)
+	}
+
+	dedup := newTestLogger(t, &ILog{Flags: log.Lmsgprefix, Sampler: &DedupSampler{Window: time.Hour}})
+	for k := 0; k < 3; k++ {
+		dedup.Info("same")
+		dedup.Warn("same")
+	}
+	dedup.Info("different")
+	if got, want := readLog(t, dedup), "INFO - same\nWARN - same\nINFO - different\n"; got != want {
+		t.Errorf("DedupSampler got %q, want %q", got, want)
+	}
+}
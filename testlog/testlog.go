@@ -0,0 +1,85 @@
+// Package testlog records the entries written by an ilogger.ILog so tests can query them
+// as structured records instead of parsing text output
+package testlog
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/jbsturgeon/ilogger"
+)
+
+// Observer is an ilogger.Sink that keeps every entry it receives
+type Observer struct {
+	mu      sync.Mutex
+	entries []ilogger.Entry
+}
+
+// Observe attaches a new Observer to l until stop is called
+func Observe(l *ilogger.ILog) (o *Observer, stop func()) {
+	o = &Observer{}
+	return o, l.AddSink(o)
+}
+
+// WriteEntry implements ilogger.Sink
+func (o *Observer) WriteEntry(e ilogger.Entry) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.entries = append(o.entries, e)
+	return nil
+}
+
+// All returns a copy of the recorded entries in the order they were written
+func (o *Observer) All() []ilogger.Entry {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	return append([]ilogger.Entry(nil), o.entries...)
+}
+
+// Len returns the number of recorded entries
+func (o *Observer) Len() int {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	return len(o.entries)
+}
+
+// Reset discards the recorded entries
+func (o *Observer) Reset() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.entries = nil
+}
+
+// FilterLevel returns a snapshot holding only the entries at level
+func (o *Observer) FilterLevel(level ilogger.LogLevel) *Observer {
+	return o.filter(func(e ilogger.Entry) bool { return e.Level == level })
+}
+
+// FilterMessage returns a snapshot holding only the entries whose message contains s
+func (o *Observer) FilterMessage(s string) *Observer {
+	return o.filter(func(e ilogger.Entry) bool { return strings.Contains(e.Message, s) })
+}
+
+// FilterField returns a snapshot holding only the entries with field key equal to value
+func (o *Observer) FilterField(key string, value interface{}) *Observer {
+	return o.filter(func(e ilogger.Entry) bool {
+		v, ok := e.Fields[key]
+		return ok && reflect.DeepEqual(v, value)
+	})
+}
+
+// filter returns a new Observer with the entries keep accepts; it is detached from any logger
+func (o *Observer) filter(keep func(ilogger.Entry) bool) *Observer {
+	f := &Observer{}
+	for _, e := range o.All() {
+		if keep(e) {
+			f.entries = append(f.entries, e)
+		}
+	}
+	return f
+}
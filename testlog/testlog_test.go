@@ -0,0 +1,77 @@
+package testlog_test
+
+import (
+	"testing"
+
+	"github.com/jbsturgeon/ilogger"
+	"github.com/jbsturgeon/ilogger/testlog"
+)
+
+func newLogger(t *testing.T) *ilogger.ILog {
+	t.Helper()
+
+	l, err := ilogger.FromStruct(&ilogger.ILog{Path: t.TempDir(), Level: ilogger.LDebug})
+	if err != nil {
+		t.Fatalf("FromStruct: %v", err)
+	}
+	t.Cleanup(func() { l.Close() })
+
+	return l
+}
+
+func TestObserverFilters(t *testing.T) {
+	l := newLogger(t)
+	o, stop := testlog.Observe(l)
+	defer stop()
+
+	l.ErrorFields("charge failed", ilogger.String("user", "ann"), ilogger.Int("attempt", 2))
+	l.ErrorFields("refund failed", ilogger.String("user", "bob"))
+	l.Warn("slow charge")
+	l.Info("charged %s", "ann")
+
+	if got := o.Len(); got != 4 {
+		t.Fatalf("Len() = %d, want 4", got)
+	}
+	if got := o.FilterLevel(ilogger.LError).Len(); got != 2 {
+		t.Errorf("FilterLevel(LError).Len() = %d, want 2", got)
+	}
+	if got := o.FilterMessage("charge").Len(); got != 3 {
+		t.Errorf("FilterMessage(charge).Len() = %d, want 3", got)
+	}
+
+	ann := o.FilterLevel(ilogger.LError).FilterField("user", "ann").All()
+	if len(ann) != 1 || ann[0].Message != "charge failed" || ann[0].Fields["attempt"] != 2 {
+		t.Errorf("FilterField(user, ann) = %+v, want the one charge failure with attempt 2", ann)
+	}
+	if got := o.FilterField("attempt", "2").Len(); got != 0 {
+		t.Errorf("FilterField matched a string against an int field %d times", got)
+	}
+
+	all := o.All()
+	if all[2].Level != ilogger.LWarn || all[3].Message != "charged ann" {
+		t.Errorf("All() out of order or unformatted: %+v", all)
+	}
+}
+
+func TestObserverResetAndStop(t *testing.T) {
+	l := newLogger(t)
+	o, stop := testlog.Observe(l)
+
+	l.Info("before reset")
+	o.Reset()
+	l.Info("after reset")
+	stop()
+	l.Info("after stop")
+
+	all := o.All()
+	if len(all) != 1 || all[0].Message != "after reset" {
+		t.Errorf("All() = %+v, want only the entry logged between Reset and stop", all)
+	}
+
+	// filters are snapshots, unaffected by later entries
+	snap := o.FilterLevel(ilogger.LInfo)
+	o.Reset()
+	if got := snap.Len(); got != 1 {
+		t.Errorf("snapshot Len() = %d after Reset, want 1", got)
+	}
+}
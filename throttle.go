@@ -0,0 +1,89 @@
+package ilogger
+
+import (
+	"sync"
+	"time"
+)
+
+// bucket is a token bucket holding up to a second's worth of entries for one level
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// throttle holds the state behind ILog.RateLimits
+type throttle struct {
+	mu      sync.Mutex
+	buckets map[LogLevel]*bucket
+	dropped uint64
+}
+
+// throttle drops entries above their level's RateLimits rate, allowing bursts of up to
+// one second's worth; levels without a limit pass untouched
+func (i *ILog) throttle(entries []Entry) []Entry {
+	now := i.now()
+
+	kept := entries[:0]
+	for _, e := range entries {
+		if i.throttler.allow(now, e.Level, i.RateLimits[e.Level]) {
+			kept = append(kept, e)
+		}
+	}
+
+	return kept
+}
+
+// allow takes a token from level's bucket, refilled at rate per second, and reports
+// whether there was one; a rate of 0 or less always allows
+func (t *throttle) allow(now time.Time, level LogLevel, rate int) bool {
+	if rate <= 0 {
+		return true
+	}
+	r := float64(rate)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.buckets == nil {
+		t.buckets = make(map[LogLevel]*bucket)
+	}
+
+	b, ok := t.buckets[level]
+	if !ok {
+		b = &bucket{tokens: r, last: now}
+		t.buckets[level] = b
+	}
+	b.tokens += now.Sub(b.last).Seconds() * r
+	if b.tokens > r {
+		b.tokens = r
+	}
+	b.last = now
+
+	if b.tokens < 1 {
+		t.dropped++
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// Throttled returns how many entries RateLimits has dropped
+func (i *ILog) Throttled() uint64 {
+	i.throttler.mu.Lock()
+	defer i.throttler.mu.Unlock()
+
+	return i.throttler.dropped
+}
+
+// RateSampler is the Sampler form of RateLimits: it caps each level in Limits at that
+// many entries per second, allowing bursts of a second's worth
+type RateSampler struct {
+	Limits map[LogLevel]int
+
+	throttle throttle
+}
+
+// ShouldLog reports whether level still has room in its bucket
+func (s *RateSampler) ShouldLog(level LogLevel, _ string) bool {
+	return s.throttle.allow(time.Now(), level, s.Limits[level])
+}
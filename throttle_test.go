@@ -0,0 +1,61 @@
+package ilogger
+
+import (
+	"log"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRateLimits(t *testing.T) {
+	clock := newFakeClock("2023-05-10T12:00:00Z")
+	l := newTestLogger(t, &ILog{Flags: log.Lmsgprefix, clock: clock.now, RateLimits: map[LogLevel]int{LInfo: 10}})
+
+	// five seconds of bursts at 100/s: the first second's burst plus a steady 10/s
+	for tick := 0; tick < 50; tick++ {
+		for n := 0; n < 10; n++ {
+			l.Info("noisy")
+		}
+		l.Warn("unlimited")
+		clock.add(100 * time.Millisecond)
+	}
+
+	out := readLog(t, l)
+	if got := strings.Count(out, "INFO - noisy"); got < 10+49 || got > 10+50 {
+		t.Errorf("kept %d INFO entries over 5s, want about 60 at 10/s plus a burst of 10", got)
+	}
+	if got := strings.Count(out, "WARN - unlimited"); got != 50 {
+		t.Errorf("kept %d WARN entries, want all 50", got)
+	}
+	if got, kept := l.Throttled(), uint64(strings.Count(out, "INFO - noisy")); got != 500-kept {
+		t.Errorf("Throttled() = %d, want %d", got, 500-kept)
+	}
+}
+
+func TestThrottleRefill(t *testing.T) {
+	var th throttle
+	start := time.Date(2023, 5, 10, 12, 0, 0, 0, time.UTC)
+
+	allowed := func(at time.Duration, n int) int {
+		got := 0
+		for ; n > 0; n-- {
+			if th.allow(start.Add(at), LInfo, 100) {
+				got++
+			}
+		}
+		return got
+	}
+
+	if got := allowed(0, 1000); got != 100 {
+		t.Errorf("burst allowed %d, want 100", got)
+	}
+	if got := allowed(500*time.Millisecond, 1000); got != 50 {
+		t.Errorf("after 0.5s allowed %d, want 50", got)
+	}
+	if got := allowed(time.Hour, 1000); got != 100 {
+		t.Errorf("after idling allowed %d, want the bucket capped at 100", got)
+	}
+	if !th.allow(start, LDebug, 0) {
+		t.Error("level without a limit was throttled")
+	}
+}
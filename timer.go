@@ -0,0 +1,88 @@
+package ilogger
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"strconv"
+	"time"
+)
+
+// Timer measures an operation started with ILog.Timer
+type Timer struct {
+	log   *ILog
+	start time.Time
+}
+
+// Timer starts timing an operation; call Stop on the result when it finishes
+func (i *ILog) Timer() *Timer {
+	return &Timer{log: i, start: time.Now()}
+}
+
+// Stop logs msg at level with the time since the Timer started as a "duration_ms" field,
+// and returns that duration
+func (t *Timer) Stop(level LogLevel, msg string) time.Duration {
+	elapsed := time.Since(t.start)
+
+	// skip entry, logOp and Stop to reach the caller
+	t.log.logOp(3, level, msg, map[string]interface{}{"duration_ms": millis(elapsed)})
+
+	return elapsed
+}
+
+// LogStart logs the start of operation name at LInfo and returns the func that logs its
+// end: LInfo on success or LError with err, carrying the duration. Both entries share
+// "operation" and "op_id" fields so the pair can be matched up.
+func (i *ILog) LogStart(name string) (stop func(err error)) {
+	fields := map[string]interface{}{"operation": name, "op_id": newOpID()}
+	start := time.Now()
+	// skip entry, logOp and LogStart to reach the caller
+	i.logOp(3, LInfo, name+" started", fields)
+
+	return func(err error) {
+		end := make(map[string]interface{}, len(fields)+2)
+		for k, v := range fields {
+			end[k] = v
+		}
+		end["duration_ms"] = millis(time.Since(start))
+
+		if err != nil {
+			end["error"] = err
+			i.logOp(3, LError, name+" failed", end)
+			return
+		}
+		i.logOp(3, LInfo, name+" finished", end)
+	}
+}
+
+// logOp writes msg at level with fields added to the entry's own; skip is the
+// runtime.Caller depth of the logging call
+func (i *ILog) logOp(skip int, level LogLevel, msg string, fields map[string]interface{}) {
+	if !i.enabled(level) {
+		return
+	}
+
+	e := i.entry(skip, time.Now(), level, true, "%s", []interface{}{msg})
+	if e.Fields == nil {
+		e.Fields = make(map[string]interface{}, len(fields))
+	}
+	for k, v := range fields {
+		e.Fields[k] = v
+	}
+
+	i.write(e)
+}
+
+// millis returns d in fractional milliseconds
+func millis(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// newOpID returns a random 16-character hex ID for LogStart
+func newOpID() string {
+	var b [8]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		// IDs only correlate entries, so a clock-based one will do
+		return strconv.FormatInt(time.Now().UnixNano(), 16)
+	}
+	return hex.EncodeToString(b[:])
+}
@@ -0,0 +1,75 @@
+package ilogger
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTimerStop(t *testing.T) {
+	l := newTestLogger(t, &ILog{Encoder: JSONEncoder{}})
+
+	const sleep = 50 * time.Millisecond
+	timer := l.Timer()
+	time.Sleep(sleep)
+	elapsed := timer.Stop(LInfo, "query done")
+
+	if elapsed < sleep || elapsed > sleep+time.Second {
+		t.Errorf("Stop returned %v after sleeping %v", elapsed, sleep)
+	}
+
+	e := jsonEntries(t, l)[0]
+	ms, ok := e["duration_ms"].(float64)
+	if !ok || ms < 50 || ms > 1050 {
+		t.Errorf("duration_ms = %v, want about 50", e["duration_ms"])
+	}
+	if ms != millis(elapsed) {
+		t.Errorf("duration_ms = %v, want the returned %v", ms, elapsed)
+	}
+	if e["msg"] != "query done" || e["level"] != "INFO" {
+		t.Errorf("unexpected entry %v", e)
+	}
+	if caller, _ := e["caller"].(string); !strings.Contains(caller, "timer_test.go:") {
+		t.Errorf("caller = %q, want this file", caller)
+	}
+}
+
+func TestLogStart(t *testing.T) {
+	l := newTestLogger(t, &ILog{Encoder: JSONEncoder{}})
+
+	stop := l.LogStart("migrate")
+	time.Sleep(10 * time.Millisecond)
+	stop(nil)
+
+	stop = l.LogStart("migrate")
+	stop(errors.New("lock timeout"))
+
+	entries := jsonEntries(t, l)
+	if len(entries) != 4 {
+		t.Fatalf("got %d entries, want 4", len(entries))
+	}
+
+	start, end := entries[0], entries[1]
+	id, _ := start["op_id"].(string)
+	if len(id) != 16 || end["op_id"] != id {
+		t.Errorf("op_id %v and %v, want a shared 16-character ID", start["op_id"], end["op_id"])
+	}
+	if start["msg"] != "migrate started" || start["operation"] != "migrate" || start["duration_ms"] != nil {
+		t.Errorf("unexpected start entry %v", start)
+	}
+	if ms, _ := end["duration_ms"].(float64); end["msg"] != "migrate finished" || end["level"] != "INFO" || ms < 10 {
+		t.Errorf("unexpected stop entry %v", end)
+	}
+
+	failed := entries[3]
+	if failed["op_id"] != entries[2]["op_id"] || failed["op_id"] == id {
+		t.Errorf("second pair op_ids %v and %v, want shared and new", entries[2]["op_id"], failed["op_id"])
+	}
+	if failed["msg"] != "migrate failed" || failed["level"] != "ERROR" || failed["error"] != "lock timeout" {
+		t.Errorf("unexpected failed entry %v", failed)
+	}
+	if _, ok := failed["duration_ms"].(float64); !ok {
+		t.Errorf("failed entry lacks duration_ms: %v", failed)
+	}
+}
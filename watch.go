@@ -0,0 +1,66 @@
+package ilogger
+
+import (
+	"io/ioutil"
+	"log"
+	"path/filepath"
+	"strings"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// WatchLevelFile applies the level named in the file at path (e.g. "DEBUG") now and
+// again whenever the file changes, until Close is called
+func (i *ILog) WatchLevelFile(path string) error {
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+
+	// watch the directory so files replaced by rename, as editors do, are still picked up;
+	// a Kubernetes ConfigMap update instead swaps the ..data symlink that path resolves
+	// through, so no event names path and any new entry in the directory means a re-read
+	if err := w.Add(filepath.Dir(path)); err != nil {
+		w.Close()
+		return err
+	}
+
+	i.applyLevelFile(path)
+
+	i.mu.Lock()
+	i.watchers = append(i.watchers, w)
+	i.mu.Unlock()
+
+	go func() {
+		for {
+			select {
+			case ev, ok := <-w.Events:
+				if !ok {
+					return
+				}
+				named := filepath.Clean(ev.Name) == filepath.Clean(path)
+				if named && ev.Op&fsnotify.Write != 0 || ev.Op&(fsnotify.Create|fsnotify.Rename) != 0 {
+					i.applyLevelFile(path)
+				}
+			case err, ok := <-w.Errors:
+				if !ok {
+					return
+				}
+				log.Printf("level file watcher (%s): %+v", path, err)
+			}
+		}
+	}()
+
+	return nil
+}
+
+// applyLevelFile sets the level from the contents of path
+func (i *ILog) applyLevelFile(path string) {
+	level, err := ioutil.ReadFile(path)
+	if err != nil {
+		log.Printf("unable to read level file (%s): %+v", path, err)
+		return
+	}
+
+	i.SetLogLevel(strings.TrimSpace(string(level)))
+}
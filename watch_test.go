@@ -0,0 +1,122 @@
+package ilogger
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// waitLevel polls until l's level is want, failing the test after a few seconds
+func waitLevel(t *testing.T, l *ILog, want LogLevel) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		got, _ := l.level()
+		if got == want {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("level = %v, want %v", got, want)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestWatchLevelFile(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "level")
+	if err := ioutil.WriteFile(name, []byte("WARN\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	l := newTestLogger(t, &ILog{Level: LError})
+	if err := l.WatchLevelFile(name); err != nil {
+		t.Fatal(err)
+	}
+	waitLevel(t, l, LWarn)
+
+	// keep logging while the watcher changes the level, for the race detector
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+				l.Info("busy")
+			}
+		}
+	}()
+
+	if err := ioutil.WriteFile(name, []byte("DEBUG\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	waitLevel(t, l, LDebug)
+
+	// an editor or ConfigMap update replaces the file rather than writing it
+	tmp := name + ".tmp"
+	if err := ioutil.WriteFile(tmp, []byte("info"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(tmp, name); err != nil {
+		t.Fatal(err)
+	}
+	waitLevel(t, l, LInfo)
+
+	close(stop)
+	<-done
+
+	// the watcher stops with the logger
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(name, []byte("ERROR\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if got, _ := l.level(); got != LInfo {
+		t.Errorf("closed logger's level changed to %v", got)
+	}
+}
+
+func TestWatchLevelFileConfigMap(t *testing.T) {
+	// a mounted ConfigMap: the key is a symlink through ..data, itself a symlink to the
+	// current timestamped directory
+	dir := t.TempDir()
+	writeVersion := func(version, level string) {
+		if err := os.Mkdir(filepath.Join(dir, version), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(dir, version, "level"), []byte(level), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeVersion("..2023_05_10_12_00_00.1", "ERROR\n")
+	if err := os.Symlink("..2023_05_10_12_00_00.1", filepath.Join(dir, "..data")); err != nil {
+		t.Fatal(err)
+	}
+	name := filepath.Join(dir, "level")
+	if err := os.Symlink(filepath.Join("..data", "level"), name); err != nil {
+		t.Fatal(err)
+	}
+
+	l := newTestLogger(t, &ILog{Level: LInfo})
+	if err := l.WatchLevelFile(name); err != nil {
+		t.Fatal(err)
+	}
+	waitLevel(t, l, LError)
+
+	// the kubelet's update: a new directory, then ..data_tmp renamed over ..data
+	writeVersion("..2023_05_10_12_05_00.2", "DEBUG\n")
+	if err := os.Symlink("..2023_05_10_12_05_00.2", filepath.Join(dir, "..data_tmp")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(filepath.Join(dir, "..data_tmp"), filepath.Join(dir, "..data")); err != nil {
+		t.Fatal(err)
+	}
+	waitLevel(t, l, LDebug)
+}